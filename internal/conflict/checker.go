@@ -7,20 +7,61 @@ import (
 	"github.com/corsc/pagerduty-gcal/internal/pduty"
 )
 
+const (
+	// ReasonMinimumDays is used when the user has another shift within the minimum days between shifts
+	ReasonMinimumDays = "minimum days between shifts"
+
+	// ReasonCalendar is used when an event in the user's calendar matched one of the calendar rules
+	// (e.g. out of office or "xoncall")
+	ReasonCalendar = "calendar event"
+
+	// SeverityHigh is used when the user has said they are not available
+	SeverityHigh = "high"
+
+	// SeverityMedium is used when the user is available but the shift breaks the scheduling policy
+	SeverityMedium = "medium"
+)
+
+// Conflict is an output DTO; it describes why the user cannot (or should not) work the shift
+type Conflict struct {
+	Entry *pduty.ScheduleEntry
+
+	// Reason is either ReasonMinimumDays or ReasonCalendar
+	Reason   string
+	Severity string
+
+	// Overlap is how much of the shift the event covers or (for ReasonMinimumDays) how far into the minimum gap
+	// after the previous shift the shift starts
+	Overlap time.Duration
+
+	// Event is the calendar item that clashes with the shift (ReasonCalendar only)
+	Event *gcal.CalendarItem
+
+	// PreviousShift is the user's earlier shift that is too close (ReasonMinimumDays only)
+	PreviousShift *pduty.ScheduleEntry
+}
+
 // CheckerAPI will compare the schedule with the calendar and return any conflicts
 type CheckerAPI struct{}
 
 // Check is the main entry point for this struct
-func (c *CheckerAPI) Check(schedule *pduty.Schedule, calendars map[string]*gcal.Calendar, daysBetweenShifts int64) ([]*pduty.ScheduleEntry, error) {
-	var conflictsOrdered []*pduty.ScheduleEntry
+func (c *CheckerAPI) Check(schedule *pduty.Schedule, calendars map[string]*gcal.Calendar, daysBetweenShifts int64) ([]*Conflict, error) {
+	var conflictsOrdered []*Conflict
+	minimumGap := time.Duration(daysBetweenShifts*24) * time.Hour
 
 	for _, scheduleEntry := range schedule.Entries {
 		scheduleUserID := scheduleEntry.User.ID
 
 		// check schedule for "minimum days between shifts" violations
-		conflict := c.checkMinimumDays(schedule, scheduleEntry, daysBetweenShifts)
-		if conflict {
-			conflictsOrdered = append(conflictsOrdered, scheduleEntry)
+		previousShift := c.checkMinimumDays(schedule, scheduleEntry, daysBetweenShifts)
+		if previousShift != nil {
+			conflictsOrdered = append(conflictsOrdered, &Conflict{
+				Entry:         scheduleEntry,
+				Reason:        ReasonMinimumDays,
+				Severity:      SeverityMedium,
+				Overlap:       previousShift.Start.Add(minimumGap).Sub(scheduleEntry.Start),
+				PreviousShift: previousShift,
+			})
 			continue
 		}
 
@@ -31,9 +72,15 @@ func (c *CheckerAPI) Check(schedule *pduty.Schedule, calendars map[string]*gcal.
 			continue
 		}
 
-		conflict = c.checkForConflict(scheduleEntry, calendar)
-		if conflict {
-			conflictsOrdered = append(conflictsOrdered, scheduleEntry)
+		event := c.findCalendarConflict(scheduleEntry, calendar)
+		if event != nil {
+			conflictsOrdered = append(conflictsOrdered, &Conflict{
+				Entry:    scheduleEntry,
+				Reason:   ReasonCalendar,
+				Severity: SeverityHigh,
+				Overlap:  overlap(scheduleEntry, event),
+				Event:    event,
+			})
 			continue
 		}
 	}
@@ -41,36 +88,71 @@ func (c *CheckerAPI) Check(schedule *pduty.Schedule, calendars map[string]*gcal.
 	return conflictsOrdered, nil
 }
 
+// CheckUnknown will return the shifts whose user's calendar could not be loaded; these are not known to be conflict free
+func (c *CheckerAPI) CheckUnknown(schedule *pduty.Schedule, calendars map[string]*gcal.Calendar) ([]*pduty.ScheduleEntry, error) {
+	var out []*pduty.ScheduleEntry
+
+	for _, scheduleEntry := range schedule.Entries {
+		calendar := calendars[scheduleEntry.User.ID]
+		if calendar != nil && calendar.Err != nil {
+			out = append(out, scheduleEntry)
+		}
+	}
+
+	return out, nil
+}
+
 func (c *CheckerAPI) checkForConflict(shift *pduty.ScheduleEntry, calendar *gcal.Calendar) bool {
+	return c.findCalendarConflict(shift, calendar) != nil
+}
+
+// returns the first calendar item that overlaps the shift or nil
+func (c *CheckerAPI) findCalendarConflict(shift *pduty.ScheduleEntry, calendar *gcal.Calendar) *gcal.CalendarItem {
 	if calendar == nil {
-		return false
+		return nil
 	}
 
 	for _, calendarEntry := range calendar.Items {
 		if calendarEntry.Start.Equal(shift.Start) {
-			return true
+			return calendarEntry
 		}
 
 		if calendarEntry.Start.After(shift.Start) {
 			if calendarEntry.Start.Before(shift.End) {
-				return true
+				return calendarEntry
 			}
 		}
 
 		if calendarEntry.Start.Before(shift.Start) {
 			if calendarEntry.End.After(shift.Start) {
-				return true
+				return calendarEntry
 			}
 		}
 	}
-	return false
+	return nil
+}
+
+// returns how long the shift and the calendar item overlap
+func overlap(shift *pduty.ScheduleEntry, item *gcal.CalendarItem) time.Duration {
+	start := shift.Start
+	if item.Start.After(start) {
+		start = item.Start
+	}
+
+	end := shift.End
+	if item.End.Before(end) {
+		end = item.End
+	}
+
+	return end.Sub(start)
 }
 
-func (c *CheckerAPI) checkMinimumDays(schedule *pduty.Schedule, currentEntry *pduty.ScheduleEntry, daysBetweenShifts int64) bool {
+// returns the user's previous shift that started less than the minimum days before this one or nil
+func (c *CheckerAPI) checkMinimumDays(schedule *pduty.Schedule, currentEntry *pduty.ScheduleEntry, daysBetweenShifts int64) *pduty.ScheduleEntry {
 	for _, previousEntry := range schedule.Entries {
 		if previousEntry.Start.After(currentEntry.Start) || previousEntry == currentEntry {
 			// don't look at the future or the same entry
-			return false
+			return nil
 		}
 
 		if currentEntry.User.ID != previousEntry.User.ID {
@@ -79,9 +161,9 @@ func (c *CheckerAPI) checkMinimumDays(schedule *pduty.Schedule, currentEntry *pd
 		}
 
 		if currentEntry.Start.Before(previousEntry.Start.Add(time.Duration(daysBetweenShifts*24) * time.Hour)) {
-			return true
+			return previousEntry
 		}
 	}
 
-	return false
+	return nil
 }
@@ -1,6 +1,7 @@
 package conflict
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -256,3 +257,100 @@ func TestCheckerAPI_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckerAPI_CheckUnknown(t *testing.T) {
+	// inputs
+	fooShift := &pduty.ScheduleEntry{
+		User:  &pduty.User{ID: testUserFoo},
+		Start: time.Date(2019, 01, 02, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2019, 01, 02, 8, 0, 0, 0, time.UTC),
+	}
+	barShift := &pduty.ScheduleEntry{
+		User:  &pduty.User{ID: testUserBar},
+		Start: time.Date(2019, 01, 02, 8, 0, 0, 0, time.UTC),
+		End:   time.Date(2019, 01, 02, 16, 0, 0, 0, time.UTC),
+	}
+	schedule := &pduty.Schedule{Entries: []*pduty.ScheduleEntry{fooShift, barShift}}
+	calendars := map[string]*gcal.Calendar{
+		testUserFoo: {},
+		testUserBar: {Err: errors.New("googleapi: Error 403: Forbidden, forbidden")},
+	}
+
+	// call
+	api := &CheckerAPI{}
+	result, resultErr := api.CheckUnknown(schedule, calendars)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, []*pduty.ScheduleEntry{barShift}, result)
+}
+
+func TestCheckerAPI_Check_details(t *testing.T) {
+	previousShift := &pduty.ScheduleEntry{
+		User:  &pduty.User{ID: testUserFoo},
+		Start: time.Date(2019, 01, 01, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2019, 01, 01, 8, 0, 0, 0, time.UTC),
+	}
+	shift := &pduty.ScheduleEntry{
+		User:  &pduty.User{ID: testUserFoo},
+		Start: time.Date(2019, 01, 02, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2019, 01, 02, 8, 0, 0, 0, time.UTC),
+	}
+	event := &gcal.CalendarItem{
+		Start:    time.Date(2019, 01, 01, 20, 0, 0, 0, time.UTC),
+		End:      time.Date(2019, 01, 02, 2, 0, 0, 0, time.UTC),
+		Rule:     "no on-call",
+		Summary:  "xoncall",
+		ID:       "event-1",
+		HTMLLink: "https://www.google.com/calendar/event?eid=event-1",
+	}
+
+	scenarios := []struct {
+		desc          string
+		inSchedule    *pduty.Schedule
+		inMinimumDays int64
+		expected      *Conflict
+	}{
+		{
+			desc:          "minimum days",
+			inSchedule:    &pduty.Schedule{Entries: []*pduty.ScheduleEntry{previousShift, shift}},
+			inMinimumDays: 3,
+			expected: &Conflict{
+				Entry:         shift,
+				Reason:        ReasonMinimumDays,
+				Severity:      SeverityMedium,
+				Overlap:       48 * time.Hour,
+				PreviousShift: previousShift,
+			},
+		},
+		{
+			desc:          "calendar event",
+			inSchedule:    &pduty.Schedule{Entries: []*pduty.ScheduleEntry{shift}},
+			inMinimumDays: 3,
+			expected: &Conflict{
+				Entry:    shift,
+				Reason:   ReasonCalendar,
+				Severity: SeverityHigh,
+				Overlap:  2 * time.Hour,
+				Event:    event,
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			calendars := map[string]*gcal.Calendar{
+				testUserFoo: {Items: []*gcal.CalendarItem{event}},
+			}
+
+			// call
+			api := &CheckerAPI{}
+			result, resultErr := api.Check(scenario.inSchedule, calendars, scenario.inMinimumDays)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, []*Conflict{scenario.expected}, result, scenario.desc)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package conflict
+
+import (
+	"sort"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+)
+
+// DoubleBooking is an output DTO; it describes 2 shifts for the same user on different schedules that overlap or
+// that do not leave enough rest between them
+type DoubleBooking struct {
+	First          *pduty.ScheduleEntry
+	FirstSchedule  *pduty.Schedule
+	Second         *pduty.ScheduleEntry
+	SecondSchedule *pduty.Schedule
+
+	// Overlap is true when the user is on-call on both schedules at the same time
+	Overlap bool
+}
+
+// CheckDoubleBookings will compare the schedules with each other and return any shifts where the same user is on-call
+// on more than one schedule at the same time or within minimumRest of each other
+func (c *CheckerAPI) CheckDoubleBookings(schedules []*pduty.Schedule, minimumRest time.Duration) ([]*DoubleBooking, error) {
+	var out []*DoubleBooking
+
+	for x, firstSchedule := range schedules {
+		for _, secondSchedule := range schedules[x+1:] {
+			out = append(out, c.checkSchedulePair(firstSchedule, secondSchedule, minimumRest)...)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].First.Start.Before(out[j].First.Start)
+	})
+
+	return out, nil
+}
+
+func (c *CheckerAPI) checkSchedulePair(a *pduty.Schedule, b *pduty.Schedule, minimumRest time.Duration) []*DoubleBooking {
+	var out []*DoubleBooking
+
+	for _, entryA := range a.Entries {
+		for _, entryB := range b.Entries {
+			if entryA.User.ID != entryB.User.ID {
+				// don't compare different users
+				continue
+			}
+
+			overlap := entryA.Start.Before(entryB.End) && entryB.Start.Before(entryA.End)
+			if !overlap && !c.tooClose(entryA, entryB, minimumRest) {
+				continue
+			}
+
+			// always report the earlier shift first
+			booking := &DoubleBooking{
+				First:          entryA,
+				FirstSchedule:  a,
+				Second:         entryB,
+				SecondSchedule: b,
+				Overlap:        overlap,
+			}
+			if entryB.Start.Before(entryA.Start) {
+				booking.First, booking.Second = entryB, entryA
+				booking.FirstSchedule, booking.SecondSchedule = b, a
+			}
+
+			out = append(out, booking)
+		}
+	}
+
+	return out
+}
+
+// returns true when the gap between the (non-overlapping) shifts is less than minimumRest
+func (c *CheckerAPI) tooClose(a *pduty.ScheduleEntry, b *pduty.ScheduleEntry, minimumRest time.Duration) bool {
+	gap := b.Start.Sub(a.End)
+	if b.Start.Before(a.Start) {
+		gap = a.Start.Sub(b.End)
+	}
+
+	return gap < minimumRest
+}
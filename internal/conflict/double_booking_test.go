@@ -0,0 +1,102 @@
+package conflict
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckerAPI_CheckDoubleBookings(t *testing.T) {
+	newSchedule := func(id string, entries ...*pduty.ScheduleEntry) *pduty.Schedule {
+		return &pduty.Schedule{ID: id, Entries: entries}
+	}
+	newEntry := func(userID string, start time.Time, hours int) *pduty.ScheduleEntry {
+		return &pduty.ScheduleEntry{
+			User:  &pduty.User{ID: userID},
+			Start: start,
+			End:   start.Add(time.Duration(hours) * time.Hour),
+		}
+	}
+
+	scenarios := []struct {
+		desc                  string
+		inSchedules           []*pduty.Schedule
+		inMinimumRest         time.Duration
+		expectedBookings      int
+		expectedOverlap       bool
+		expectedFirstSchedule string
+	}{
+		{
+			desc:             "happy path - no inputs",
+			inSchedules:      nil,
+			expectedBookings: 0,
+		},
+		{
+			desc: "no double booking - different users",
+			inSchedules: []*pduty.Schedule{
+				newSchedule("PRIMARY", newEntry(testUserFoo, day2Morning, 8)),
+				newSchedule("SECONDARY", newEntry(testUserBar, day2Morning, 8)),
+			},
+			inMinimumRest:    8 * time.Hour,
+			expectedBookings: 0,
+		},
+		{
+			desc: "double booking - same user at the same time",
+			inSchedules: []*pduty.Schedule{
+				newSchedule("PRIMARY", newEntry(testUserFoo, day2Morning, 8)),
+				newSchedule("SECONDARY", newEntry(testUserFoo, day2Morning.Add(4*time.Hour), 8)),
+			},
+			expectedBookings:      1,
+			expectedOverlap:       true,
+			expectedFirstSchedule: "PRIMARY",
+		},
+		{
+			desc: "double booking - not enough rest (reported in time order)",
+			inSchedules: []*pduty.Schedule{
+				newSchedule("PRIMARY", newEntry(testUserFoo, day2Afternoon, 8)),
+				newSchedule("SECONDARY", newEntry(testUserFoo, day2Morning, 8)),
+			},
+			inMinimumRest:         8 * time.Hour,
+			expectedBookings:      1,
+			expectedOverlap:       false,
+			expectedFirstSchedule: "SECONDARY",
+		},
+		{
+			desc: "no double booking - enough rest",
+			inSchedules: []*pduty.Schedule{
+				newSchedule("PRIMARY", newEntry(testUserFoo, day2Morning, 8)),
+				newSchedule("SECONDARY", newEntry(testUserFoo, day3Morning, 8)),
+			},
+			inMinimumRest:    8 * time.Hour,
+			expectedBookings: 0,
+		},
+		{
+			desc: "no double booking - same schedule is not compared with itself",
+			inSchedules: []*pduty.Schedule{
+				newSchedule("PRIMARY", newEntry(testUserFoo, day2Morning, 8), newEntry(testUserFoo, day2Afternoon, 8)),
+			},
+			inMinimumRest:    8 * time.Hour,
+			expectedBookings: 0,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			api := &CheckerAPI{}
+			result, resultErr := api.CheckDoubleBookings(scenario.inSchedules, scenario.inMinimumRest)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedBookings, len(result), scenario.desc)
+			if len(result) > 0 {
+				assert.Equal(t, scenario.expectedOverlap, result[0].Overlap, scenario.desc)
+				assert.Equal(t, scenario.expectedFirstSchedule, result[0].FirstSchedule.ID, scenario.desc)
+				assert.False(t, result[0].Second.Start.Before(result[0].First.Start), scenario.desc)
+			}
+		})
+	}
+}
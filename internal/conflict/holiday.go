@@ -0,0 +1,31 @@
+package conflict
+
+import (
+	"github.com/corsc/pagerduty-gcal/internal/holiday"
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+)
+
+// HolidayConflict is an output DTO; it describes a shift that falls on a public holiday of the user's region.
+// These are soft conflicts; the user may still be available
+type HolidayConflict struct {
+	Entry   *pduty.ScheduleEntry
+	Holiday *holiday.Holiday
+}
+
+// CheckHolidays will return the shifts that overlap a public holiday of the user's region (holidays are mapped by PD user id)
+func (c *CheckerAPI) CheckHolidays(schedule *pduty.Schedule, holidays map[string][]*holiday.Holiday) ([]*HolidayConflict, error) {
+	var out []*HolidayConflict
+
+	for _, scheduleEntry := range schedule.Entries {
+		for _, thisHoliday := range holidays[scheduleEntry.User.ID] {
+			if thisHoliday.Start.Before(scheduleEntry.End) && thisHoliday.End.After(scheduleEntry.Start) {
+				out = append(out, &HolidayConflict{
+					Entry:   scheduleEntry,
+					Holiday: thisHoliday,
+				})
+			}
+		}
+	}
+
+	return out, nil
+}
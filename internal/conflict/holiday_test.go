@@ -0,0 +1,76 @@
+package conflict
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/holiday"
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckerAPI_CheckHolidays(t *testing.T) {
+	schedule := &pduty.Schedule{
+		Entries: []*pduty.ScheduleEntry{day2MorningSource},
+	}
+
+	singapore, err := time.LoadLocation("Asia/Singapore")
+	assert.Nil(t, err)
+
+	scenarios := []struct {
+		desc              string
+		inHolidays        map[string][]*holiday.Holiday
+		expectedConflicts int
+	}{
+		{
+			desc:              "no region",
+			inHolidays:        map[string][]*holiday.Holiday{},
+			expectedConflicts: 0,
+		},
+		{
+			desc: "holiday during the shift",
+			inHolidays: map[string][]*holiday.Holiday{
+				sourceUserID: {
+					{Name: "New Year's Day", Start: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)},
+					{Name: "Holiday", Start: day2Morning, End: day3Morning},
+				},
+			},
+			expectedConflicts: 1,
+		},
+		{
+			desc: "holiday in another time zone overlaps the start of the shift",
+			inHolidays: map[string][]*holiday.Holiday{
+				sourceUserID: {
+					// 2019-01-01 16:00 to 2019-01-02 16:00 UTC
+					{Name: "Holiday", Start: time.Date(2019, 1, 2, 0, 0, 0, 0, singapore), End: time.Date(2019, 1, 3, 0, 0, 0, 0, singapore)},
+				},
+			},
+			expectedConflicts: 1,
+		},
+		{
+			desc: "holiday of another user",
+			inHolidays: map[string][]*holiday.Holiday{
+				destinationUserID: {
+					{Name: "Holiday", Start: day2Morning, End: day3Morning},
+				},
+			},
+			expectedConflicts: 0,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			api := &CheckerAPI{}
+			result, resultErr := api.CheckHolidays(schedule, scenario.inHolidays)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedConflicts, len(result), scenario.desc)
+			for _, holidayConflict := range result {
+				assert.Equal(t, day2MorningSource, holidayConflict.Entry, scenario.desc)
+			}
+		})
+	}
+}
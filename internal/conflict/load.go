@@ -0,0 +1,102 @@
+package conflict
+
+import (
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+)
+
+const (
+	businessHoursStart = 9
+	businessHoursEnd   = 18
+)
+
+// UserLoad is an output DTO; it summarises the incidents a user received while on-call
+type UserLoad struct {
+	User      *pduty.User
+	Shifts    int
+	Incidents int
+
+	// AfterHoursPages is the number of incidents created outside of business hours (in the user's time zone)
+	AfterHoursPages int
+
+	// MinutesAcknowledged is the time between acknowledging and resolving the (resolved) incidents
+	MinutesAcknowledged float64
+}
+
+// score is used to compare the load of users; pages outside of business hours count double
+func (u *UserLoad) score() int {
+	if u == nil {
+		return 0
+	}
+
+	return u.Incidents + u.AfterHoursPages
+}
+
+// LoadAPI will attribute incidents to the on-call shift at the time
+type LoadAPI struct{}
+
+// Calculate returns the load of each user (mapped by PD user id) in the (historical) schedule.
+// Locations (mapped by PD user id) are used to determine business hours; users without one use UTC
+func (l *LoadAPI) Calculate(schedule *pduty.Schedule, incidents []*pduty.Incident, locations map[string]*time.Location) (map[string]*UserLoad, error) {
+	out := map[string]*UserLoad{}
+
+	for _, scheduleEntry := range schedule.Entries {
+		l.getLoad(out, scheduleEntry.User).Shifts++
+	}
+
+	for _, incident := range incidents {
+		scheduleEntry := l.findOnCall(schedule, incident.CreatedAt)
+		if scheduleEntry == nil {
+			continue
+		}
+
+		load := l.getLoad(out, scheduleEntry.User)
+		load.Incidents++
+
+		location := locations[scheduleEntry.User.ID]
+		if location == nil {
+			location = time.UTC
+		}
+
+		if l.isAfterHours(incident.CreatedAt.In(location)) {
+			load.AfterHoursPages++
+		}
+
+		acknowledgedAt, resolvedAt := incident.AcknowledgedAt(), incident.ResolvedAt()
+		if !acknowledgedAt.IsZero() && resolvedAt.After(acknowledgedAt) {
+			load.MinutesAcknowledged += resolvedAt.Sub(acknowledgedAt).Minutes()
+		}
+	}
+
+	return out, nil
+}
+
+func (l *LoadAPI) getLoad(loads map[string]*UserLoad, user *pduty.User) *UserLoad {
+	out, found := loads[user.ID]
+	if !found {
+		out = &UserLoad{User: user}
+		loads[user.ID] = out
+	}
+
+	return out
+}
+
+// return the schedule entry that was on-call at the supplied time
+func (l *LoadAPI) findOnCall(schedule *pduty.Schedule, at time.Time) *pduty.ScheduleEntry {
+	for _, scheduleEntry := range schedule.Entries {
+		if !at.Before(scheduleEntry.Start) && at.Before(scheduleEntry.End) {
+			return scheduleEntry
+		}
+	}
+
+	return nil
+}
+
+func (l *LoadAPI) isAfterHours(localTime time.Time) bool {
+	if localTime.Weekday() == time.Saturday || localTime.Weekday() == time.Sunday {
+		return true
+	}
+
+	return localTime.Hour() < businessHoursStart || localTime.Hour() >= businessHoursEnd
+}
@@ -0,0 +1,74 @@
+package conflict
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAPI_Calculate(t *testing.T) {
+	// 2019-01-02 is a Wednesday
+	schedule := &pduty.Schedule{
+		Entries: []*pduty.ScheduleEntry{
+			day2MorningSource,
+			{User: &pduty.User{ID: destinationUserID}, Start: day2Afternoon, End: day2Evening},
+		},
+	}
+
+	singapore, err := time.LoadLocation("Asia/Singapore")
+	if err != nil {
+		t.Skipf("time zone data not available: %s", err)
+	}
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skipf("time zone data not available: %s", err)
+	}
+
+	newIncident := func(createdAt time.Time, acknowledgedAfter time.Duration, resolvedAfter time.Duration) *pduty.Incident {
+		out := &pduty.Incident{CreatedAt: createdAt}
+		if acknowledgedAfter > 0 {
+			out.Acknowledgements = []*struct{ At time.Time }{{At: createdAt.Add(acknowledgedAfter)}}
+		}
+		if resolvedAfter > 0 {
+			// PD clears the acknowledgements of resolved incidents
+			out.Status = "resolved"
+			out.LastStatusChangeAt = createdAt.Add(resolvedAfter)
+			out.FirstAcknowledgedAt = createdAt.Add(acknowledgedAfter)
+			out.Acknowledgements = nil
+		}
+		return out
+	}
+
+	incidents := []*pduty.Incident{
+		// 00:30 UTC = 08:30 in Singapore (after hours)
+		newIncident(day2Morning.Add(30*time.Minute), 10*time.Minute, 40*time.Minute),
+		// 04:00 UTC = 12:00 in Singapore (business hours)
+		newIncident(day2Morning.Add(4*time.Hour), 0, 0),
+		// 10:00 UTC = 21:00 in Sydney (after hours)
+		newIncident(day2Afternoon.Add(2*time.Hour), 5*time.Minute, 0),
+		// nobody on-call
+		newIncident(day3Morning, 0, 0),
+	}
+
+	// call
+	api := &LoadAPI{}
+	result, resultErr := api.Calculate(schedule, incidents, map[string]*time.Location{sourceUserID: singapore, destinationUserID: sydney})
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result))
+
+	source := result[sourceUserID]
+	assert.Equal(t, 1, source.Shifts)
+	assert.Equal(t, 2, source.Incidents)
+	assert.Equal(t, 1, source.AfterHoursPages)
+	assert.Equal(t, float64(30), source.MinutesAcknowledged)
+
+	destination := result[destinationUserID]
+	assert.Equal(t, 1, destination.Shifts)
+	assert.Equal(t, 1, destination.Incidents)
+	assert.Equal(t, 1, destination.AfterHoursPages)
+	assert.Equal(t, float64(0), destination.MinutesAcknowledged)
+}
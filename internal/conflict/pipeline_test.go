@@ -0,0 +1,60 @@
+package conflict
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/gcal"
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+	"github.com/corsc/pagerduty-gcal/internal/pduty/pdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// load the schedule and users from a fake PD, then check and swap as main.go does
+func TestPipeline(t *testing.T) {
+	server := pdtest.NewServer("key")
+	defer server.Close()
+
+	server.AddUser(&pdtest.User{ID: sourceUserID, Name: "Foo", Email: "foo@example.com"})
+	server.AddUser(&pdtest.User{ID: destinationUserID, Name: "Bar", Email: "bar@example.com"})
+	server.AddSchedule(&pdtest.Schedule{
+		ID: "SCHED",
+		Entries: []*pdtest.ScheduleEntry{
+			{Start: day2Morning, End: day2Afternoon, UserID: sourceUserID},
+			{Start: day3Morning, End: day3Afternoon, UserID: destinationUserID},
+		},
+	})
+
+	options := []pduty.Option{pduty.WithAPIKey("key"), pduty.WithBaseURL(server.URL)}
+
+	schedule, err := pduty.NewScheduleAPI(options...).GetSchedule("SCHED", periodStart, periodStart.Add(7*24*time.Hour))
+	assert.Nil(t, err)
+
+	users, err := pduty.NewUserAPI(options...).GetUsers(schedule.Entries)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{sourceUserID: "foo@example.com", destinationUserID: "bar@example.com"}, users)
+
+	// source user is out of office during their shift
+	calendars := map[string]*gcal.Calendar{
+		sourceUserID: {
+			Items: []*gcal.CalendarItem{
+				{Start: day2Morning, End: day2Afternoon},
+			},
+		},
+		destinationUserID: {},
+	}
+
+	conflicts, err := (&CheckerAPI{}).Check(schedule, calendars, 0)
+	assert.Nil(t, err)
+	if !assert.Equal(t, 1, len(conflicts)) {
+		return
+	}
+	assert.Equal(t, sourceUserID, conflicts[0].Entry.User.ID)
+
+	swap := (&SwapAPI{}).FindSwap(periodStart, schedule, conflicts[0].Entry, calendars)
+	if !assert.NotNil(t, swap) {
+		return
+	}
+	assert.Equal(t, destinationUserID, swap.User.ID)
+	assert.True(t, swap.Start.Equal(day3Morning))
+}
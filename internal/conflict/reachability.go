@@ -0,0 +1,102 @@
+package conflict
+
+import (
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+)
+
+const (
+	// ReasonNoSetup is used when the user's contact methods and notification rules could not be loaded
+	ReasonNoSetup = "notification setup unknown"
+
+	// ReasonNoUrgentContactMethod is used when the user has no phone, SMS or push contact method
+	ReasonNoUrgentContactMethod = "no phone, SMS or push contact method"
+
+	// ReasonNoHighUrgencyRule is used when none of the user's notification rules for high-urgency incidents use a phone,
+	// SMS or push contact method
+	ReasonNoHighUrgencyRule = "no high-urgency notification rule for phone, SMS or push"
+)
+
+// contact methods that can wake someone up
+var urgentContactMethods = map[string]bool{
+	"phone_contact_method":             true,
+	"sms_contact_method":               true,
+	"push_notification_contact_method": true,
+}
+
+// Unreachable is an output DTO; it describes a shift whose user PD may not be able to reach
+type Unreachable struct {
+	Entry   *pduty.ScheduleEntry
+	Reasons []string
+}
+
+// CheckReachability will return the shifts whose user has no way to be notified of high-urgency incidents
+func (c *CheckerAPI) CheckReachability(schedule *pduty.Schedule, setups map[string]*pduty.NotificationSetup) ([]*Unreachable, error) {
+	var out []*Unreachable
+
+	for _, scheduleEntry := range schedule.Entries {
+		reasons := c.checkNotificationSetup(setups[scheduleEntry.User.ID])
+		if len(reasons) == 0 {
+			continue
+		}
+
+		out = append(out, &Unreachable{
+			Entry:   scheduleEntry,
+			Reasons: reasons,
+		})
+	}
+
+	return out, nil
+}
+
+func (c *CheckerAPI) checkNotificationSetup(setup *pduty.NotificationSetup) []string {
+	if setup == nil {
+		return []string{ReasonNoSetup}
+	}
+
+	var out []string
+
+	hasUrgentContactMethod := false
+	for _, contactMethod := range setup.ContactMethods {
+		if urgentContactMethods[contactMethod.Kind()] {
+			hasUrgentContactMethod = true
+			break
+		}
+	}
+
+	if !hasUrgentContactMethod {
+		out = append(out, ReasonNoUrgentContactMethod)
+	}
+
+	hasHighUrgencyRule := false
+	for _, rule := range setup.NotificationRules {
+		if rule.Urgency == "high" && c.isUrgent(rule.ContactMethod, setup.ContactMethods) {
+			hasHighUrgencyRule = true
+			break
+		}
+	}
+
+	if !hasHighUrgencyRule {
+		out = append(out, ReasonNoHighUrgencyRule)
+	}
+
+	return out
+}
+
+// the contact method of a rule is a reference; its type is taken from the user's contact methods when it is missing
+func (c *CheckerAPI) isUrgent(contactMethod *pduty.ContactMethod, contactMethods []*pduty.ContactMethod) bool {
+	if contactMethod == nil {
+		return false
+	}
+
+	if contactMethod.Type != "" {
+		return urgentContactMethods[contactMethod.Kind()]
+	}
+
+	for _, thisMethod := range contactMethods {
+		if thisMethod.ID == contactMethod.ID {
+			return urgentContactMethods[thisMethod.Kind()]
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package conflict
+
+import (
+	"testing"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckerAPI_CheckReachability(t *testing.T) {
+	schedule := &pduty.Schedule{
+		Entries: []*pduty.ScheduleEntry{day2MorningSource},
+	}
+
+	phone := &pduty.ContactMethod{ID: "PHONE", Type: "phone_contact_method"}
+	email := &pduty.ContactMethod{ID: "EMAIL", Type: "email_contact_method"}
+
+	scenarios := []struct {
+		desc            string
+		inSetup         *pduty.NotificationSetup
+		expectedReasons []string
+	}{
+		{
+			desc: "happy path - reachable",
+			inSetup: &pduty.NotificationSetup{
+				ContactMethods: []*pduty.ContactMethod{phone, email},
+				NotificationRules: []*pduty.NotificationRule{
+					{Urgency: "high", ContactMethod: &pduty.ContactMethod{ID: "PHONE", Type: "phone_contact_method_reference"}},
+				},
+			},
+			expectedReasons: nil,
+		},
+		{
+			desc:            "unreachable - setup unknown",
+			inSetup:         nil,
+			expectedReasons: []string{ReasonNoSetup},
+		},
+		{
+			desc: "unreachable - email only",
+			inSetup: &pduty.NotificationSetup{
+				ContactMethods: []*pduty.ContactMethod{email},
+				NotificationRules: []*pduty.NotificationRule{
+					{Urgency: "high", ContactMethod: email},
+				},
+			},
+			expectedReasons: []string{ReasonNoUrgentContactMethod, ReasonNoHighUrgencyRule},
+		},
+		{
+			desc: "unreachable - high urgency rule only emails",
+			inSetup: &pduty.NotificationSetup{
+				ContactMethods: []*pduty.ContactMethod{phone, email},
+				NotificationRules: []*pduty.NotificationRule{
+					{Urgency: "high", ContactMethod: &pduty.ContactMethod{ID: "EMAIL", Type: "email_contact_method_reference"}},
+					{Urgency: "low", ContactMethod: &pduty.ContactMethod{ID: "PHONE", Type: "phone_contact_method_reference"}},
+				},
+			},
+			expectedReasons: []string{ReasonNoHighUrgencyRule},
+		},
+		{
+			desc: "happy path - rule without the contact method type",
+			inSetup: &pduty.NotificationSetup{
+				ContactMethods: []*pduty.ContactMethod{phone},
+				NotificationRules: []*pduty.NotificationRule{
+					{Urgency: "high", ContactMethod: &pduty.ContactMethod{ID: "PHONE"}},
+				},
+			},
+			expectedReasons: nil,
+		},
+		{
+			desc: "unreachable - no high urgency rule",
+			inSetup: &pduty.NotificationSetup{
+				ContactMethods: []*pduty.ContactMethod{phone},
+				NotificationRules: []*pduty.NotificationRule{
+					{Urgency: "low", ContactMethod: phone},
+				},
+			},
+			expectedReasons: []string{ReasonNoHighUrgencyRule},
+		},
+		{
+			desc:            "unreachable - nothing configured",
+			inSetup:         &pduty.NotificationSetup{},
+			expectedReasons: []string{ReasonNoUrgentContactMethod, ReasonNoHighUrgencyRule},
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			setups := map[string]*pduty.NotificationSetup{}
+			if scenario.inSetup != nil {
+				setups[sourceUserID] = scenario.inSetup
+			}
+
+			// call
+			api := &CheckerAPI{}
+			result, resultErr := api.CheckReachability(schedule, setups)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			if scenario.expectedReasons == nil {
+				assert.Equal(t, 0, len(result), scenario.desc)
+				return
+			}
+
+			if assert.Equal(t, 1, len(result), scenario.desc) {
+				assert.Equal(t, day2MorningSource, result[0].Entry, scenario.desc)
+				assert.Equal(t, scenario.expectedReasons, result[0].Reasons, scenario.desc)
+			}
+		})
+	}
+}
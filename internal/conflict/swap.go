@@ -9,8 +9,16 @@ import (
 
 // SwapAPI will attempt to find a swap in the schedule
 type SwapAPI struct {
+	// LockOverrides prevents entries that are (or are covered by) an existing override from being swapped
+	LockOverrides bool
+
+	// Load (mapped by PD user id) is optional; when supplied the candidate with the lowest recent incident load is
+	// chosen instead of the first one found
+	Load map[string]*UserLoad
+
 	checker *CheckerAPI
 
+	// proposedSwaps are the slots (both the conflicts and their swaps) that are already part of a swap
 	proposedSwaps []*pduty.ScheduleEntry
 }
 
@@ -20,6 +28,23 @@ func (s *SwapAPI) FindSwap(periodStart time.Time, schedule *pduty.Schedule, conf
 		s.checker = &CheckerAPI{}
 	}
 
+	if conflict.Start.Before(periodStart) {
+		// shifts before the period are only loaded to check the days between shifts
+		return nil
+	}
+
+	if s.isAlreadySwapped(conflict) {
+		// the conflict slot was already used by a previous swap
+		return nil
+	}
+
+	if s.LockOverrides && conflict.IsOverride {
+		// someone has already manually arranged this shift
+		return nil
+	}
+
+	var bestSwap *pduty.ScheduleEntry
+
 	for _, potentialSwap := range schedule.Entries {
 		if potentialSwap.Start.Equal(conflict.Start) && potentialSwap.End.Equal(conflict.End) ||
 			potentialSwap.User.ID == conflict.User.ID {
@@ -32,7 +57,23 @@ func (s *SwapAPI) FindSwap(periodStart time.Time, schedule *pduty.Schedule, conf
 			continue
 		}
 
-		if !s.timeEqual(potentialSwap.Start, conflict.Start) || !s.timeEqual(potentialSwap.End, conflict.End) {
+		if !s.sameSlot(potentialSwap, conflict) {
+			// can only swap with users that cover the same slot (layer)
+			continue
+		}
+
+		if s.LockOverrides && potentialSwap.IsOverride {
+			// don't undo someone else's manual arrangement
+			continue
+		}
+
+		if s.isSwapBack(schedule, conflict, potentialSwap) {
+			// the conflict is an override; swapping with the user it replaced would undo it
+			continue
+		}
+
+		if calendar := calendars[potentialSwap.User.ID]; calendar != nil && calendar.Err != nil {
+			// potential swap user's availability is unknown
 			continue
 		}
 
@@ -51,12 +92,31 @@ func (s *SwapAPI) FindSwap(periodStart time.Time, schedule *pduty.Schedule, conf
 			continue
 		}
 
-		s.proposedSwaps = append(s.proposedSwaps, potentialSwap)
+		if s.Load == nil {
+			s.proposedSwaps = append(s.proposedSwaps, conflict, potentialSwap)
+			return potentialSwap
+		}
 
-		return potentialSwap
+		if bestSwap == nil || s.Load[potentialSwap.User.ID].score() < s.Load[bestSwap.User.ID].score() {
+			bestSwap = potentialSwap
+		}
+	}
+
+	if bestSwap != nil {
+		s.proposedSwaps = append(s.proposedSwaps, conflict, bestSwap)
 	}
 
-	return nil
+	return bestSwap
+}
+
+// returns true when both entries were rendered from the same schedule layer.
+// When the layer is not known, fallback to comparing the hour and minute of the shift
+func (s *SwapAPI) sameSlot(a *pduty.ScheduleEntry, b *pduty.ScheduleEntry) bool {
+	if a.LayerID != "" && b.LayerID != "" {
+		return a.LayerID == b.LayerID
+	}
+
+	return s.timeEqual(a.Start, b.Start) && s.timeEqual(a.End, b.End)
 }
 
 // compare the hour and minute only
@@ -64,9 +124,28 @@ func (s *SwapAPI) timeEqual(a time.Time, b time.Time) bool {
 	return a.Hour() == b.Hour() && a.Minute() == b.Minute()
 }
 
-func (s *SwapAPI) isAlreadySwapped(potentialSwap *pduty.ScheduleEntry) bool {
+// returns true when either entry is an override and the other entry's user is the one that the override replaced
+func (s *SwapAPI) isSwapBack(schedule *pduty.Schedule, conflict *pduty.ScheduleEntry, potentialSwap *pduty.ScheduleEntry) bool {
+	if conflict.IsOverride {
+		original := pduty.OriginalUser(schedule, conflict)
+		if original != nil && original.ID == potentialSwap.User.ID {
+			return true
+		}
+	}
+
+	if potentialSwap.IsOverride {
+		original := pduty.OriginalUser(schedule, potentialSwap)
+		if original != nil && original.ID == conflict.User.ID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *SwapAPI) isAlreadySwapped(entry *pduty.ScheduleEntry) bool {
 	for _, thisSwap := range s.proposedSwaps {
-		if thisSwap.Start.Equal(potentialSwap.Start) && thisSwap.End.Equal(potentialSwap.End) {
+		if thisSwap.Start.Equal(entry.Start) && thisSwap.End.Equal(entry.End) {
 			return true
 		}
 	}
@@ -1,6 +1,7 @@
 package conflict
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ var (
 
 	sourceUserID      = "FOO"
 	destinationUserID = "BAR"
+	otherUserID       = "BAZ"
 
 	dayPastMorning   = time.Date(2018, 12, 30, 0, 0, 0, 0, time.UTC)
 	dayPastAfternoon = time.Date(2018, 12, 30, 8, 0, 0, 0, time.UTC)
@@ -49,6 +51,60 @@ var (
 		End:   day3Afternoon,
 	}
 
+	day2MorningLayer1Source = &pduty.ScheduleEntry{
+		User: &pduty.User{
+			ID: sourceUserID,
+		},
+		Start:   day2Morning,
+		End:     day2Afternoon,
+		LayerID: "LAYER1",
+	}
+
+	day3MorningLayer2Destination = &pduty.ScheduleEntry{
+		User: &pduty.User{
+			ID: destinationUserID,
+		},
+		Start:   day3Morning,
+		End:     day3Afternoon,
+		LayerID: "LAYER2",
+	}
+
+	day3AfternoonLayer1Destination = &pduty.ScheduleEntry{
+		User: &pduty.User{
+			ID: destinationUserID,
+		},
+		Start:   day3Afternoon,
+		End:     day3Afternoon.Add(8 * time.Hour),
+		LayerID: "LAYER1",
+	}
+
+	day2MorningOverrideSource = &pduty.ScheduleEntry{
+		User: &pduty.User{
+			ID: sourceUserID,
+		},
+		Start:      day2Morning,
+		End:        day2Afternoon,
+		LayerID:    "LAYER1",
+		IsOverride: true,
+	}
+
+	day3MorningOverrideDestination = &pduty.ScheduleEntry{
+		User: &pduty.User{
+			ID: destinationUserID,
+		},
+		Start:      day3Morning,
+		End:        day3Afternoon,
+		IsOverride: true,
+	}
+
+	day4MorningOtherDestination = &pduty.ScheduleEntry{
+		User: &pduty.User{
+			ID: otherUserID,
+		},
+		Start: day3Morning.Add(24 * time.Hour),
+		End:   day3Afternoon.Add(24 * time.Hour),
+	}
+
 	dayPastMorningDestination = &pduty.ScheduleEntry{
 		User: &pduty.User{
 			ID: destinationUserID,
@@ -65,6 +121,8 @@ func TestSwapAPI_FindSwap(t *testing.T) {
 		inConflict       *pduty.ScheduleEntry
 		inCalendars      map[string]*gcal.Calendar
 		inAlreadySwapped []*pduty.ScheduleEntry
+		inLockOverrides  bool
+		inLoad           map[string]*UserLoad
 		expected         *pduty.ScheduleEntry
 	}{
 		{
@@ -82,6 +140,21 @@ func TestSwapAPI_FindSwap(t *testing.T) {
 			},
 			expected: day3MorningDestination,
 		},
+		{
+			desc: "no swap - destination calendar unavailable",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningSource,
+					day3MorningDestination,
+				},
+			},
+			inConflict: day2MorningSource,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {Err: errors.New("googleapi: Error 404: Not Found, notFound")},
+			},
+			expected: nil,
+		},
 		{
 			desc: "swap not possible, in the past",
 			inSchedule: &pduty.Schedule{
@@ -159,6 +232,131 @@ func TestSwapAPI_FindSwap(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			desc: "swap available in the same layer",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningLayer1Source,
+					day3MorningLayer2Destination,
+					day3AfternoonLayer1Destination,
+				},
+			},
+			inConflict: day2MorningLayer1Source,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {},
+			},
+			expected: day3AfternoonLayer1Destination,
+		},
+		{
+			desc: "swap not possible, different layer",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningLayer1Source,
+					day3MorningLayer2Destination,
+				},
+			},
+			inConflict: day2MorningLayer1Source,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {},
+			},
+			expected: nil,
+		},
+		{
+			desc: "override locked - conflict is an override",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningOverrideSource,
+					day3AfternoonLayer1Destination,
+				},
+			},
+			inConflict: day2MorningOverrideSource,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {},
+			},
+			inLockOverrides: true,
+			expected:        nil,
+		},
+		{
+			desc: "override locked - swap is an override",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningSource,
+					day3MorningOverrideDestination,
+				},
+			},
+			inConflict: day2MorningSource,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {},
+			},
+			inLockOverrides: true,
+			expected:        nil,
+		},
+		{
+			desc: "override not locked - swap is an override",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningSource,
+					day3MorningOverrideDestination,
+				},
+			},
+			inConflict: day2MorningSource,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {},
+			},
+			inLockOverrides: false,
+			expected:        day3MorningOverrideDestination,
+		},
+		{
+			desc: "override not locked - do not swap back to the replaced user",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningOverrideSource,
+					day3AfternoonLayer1Destination,
+				},
+				Layers: []*pduty.ScheduleLayer{
+					{
+						ID: "LAYER1",
+						Entries: []*pduty.ScheduleEntry{
+							{User: &pduty.User{ID: destinationUserID}, Start: day2Morning, End: day2Afternoon},
+							{User: &pduty.User{ID: destinationUserID}, Start: day3Afternoon, End: day3Afternoon.Add(8 * time.Hour)},
+						},
+					},
+				},
+			},
+			inConflict: day2MorningOverrideSource,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {},
+			},
+			inLockOverrides: false,
+			expected:        nil,
+		},
+		{
+			desc: "prefer the candidate with the lowest load",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningSource,
+					day3MorningDestination,
+					day4MorningOtherDestination,
+				},
+			},
+			inConflict: day2MorningSource,
+			inCalendars: map[string]*gcal.Calendar{
+				sourceUserID:      {},
+				destinationUserID: {},
+				otherUserID:       {},
+			},
+			inLoad: map[string]*UserLoad{
+				destinationUserID: {Incidents: 5, AfterHoursPages: 2},
+				otherUserID:       {Incidents: 1},
+			},
+			expected: day4MorningOtherDestination,
+		},
 		{
 			desc: "cannot swap with yourself",
 			inSchedule: &pduty.Schedule{
@@ -172,6 +370,29 @@ func TestSwapAPI_FindSwap(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			desc: "conflict before the period",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					dayPastMorningDestination,
+					day2MorningSource,
+				},
+			},
+			inConflict: dayPastMorningDestination,
+			expected:   nil,
+		},
+		{
+			desc: "conflict slot already used by a previous swap",
+			inSchedule: &pduty.Schedule{
+				Entries: []*pduty.ScheduleEntry{
+					day2MorningSource,
+					day3MorningDestination,
+				},
+			},
+			inConflict:       day3MorningDestination,
+			inAlreadySwapped: []*pduty.ScheduleEntry{day3MorningDestination},
+			expected:         nil,
+		},
 	}
 
 	for _, s := range scenarios {
@@ -179,6 +400,8 @@ func TestSwapAPI_FindSwap(t *testing.T) {
 		t.Run(scenario.desc, func(t *testing.T) {
 			// call
 			api := &SwapAPI{
+				LockOverrides: scenario.inLockOverrides,
+				Load:          scenario.inLoad,
 				proposedSwaps: scenario.inAlreadySwapped,
 			}
 			result := api.FindSwap(periodStart, scenario.inSchedule, scenario.inConflict, scenario.inCalendars)
@@ -188,3 +411,22 @@ func TestSwapAPI_FindSwap(t *testing.T) {
 		})
 	}
 }
+
+func TestSwapAPI_FindSwap_adjacentConflicts(t *testing.T) {
+	schedule := &pduty.Schedule{
+		Entries: []*pduty.ScheduleEntry{
+			day2MorningSource,
+			day3MorningDestination,
+			day4MorningOtherDestination,
+		},
+	}
+
+	// call
+	api := &SwapAPI{}
+	first := api.FindSwap(periodStart, schedule, day2MorningSource, nil)
+	second := api.FindSwap(periodStart, schedule, day3MorningDestination, nil)
+
+	// validate
+	assert.Equal(t, day3MorningDestination, first)
+	assert.Nil(t, second, "the second conflict's slot is already part of the first swap")
+}
@@ -0,0 +1,112 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/calendar/v3"
+)
+
+const (
+	// AuthModeOAuth reads the calendars as the user that completes the OAuth flow in the browser
+	AuthModeOAuth = "oauth"
+
+	// AuthModeServiceAccount reads the calendars using a service account key with domain-wide delegation
+	AuthModeServiceAccount = "service-account"
+)
+
+// returns the client and API to use when reading the calendar of the supplied email address
+type apiFactory func(email string) (*http.Client, *calendar.Service, error)
+
+// builds the apiFactory for the configured auth mode; scope is either calendar.CalendarReadonlyScope or
+// calendar.CalendarEventsScope (only needed to publish shifts)
+func (c *CalendarAPI) getAPIs(credsFile, tokFile string, scope string) (apiFactory, error) {
+	switch c.AuthMode {
+	case "", AuthModeOAuth:
+		tokens := c.Tokens
+		if tokens == nil {
+			tokens = &FileTokenStore{Path: tokFile}
+		}
+
+		client, api, err := c.getAPI(credsFile, tokens, scope)
+		if err != nil {
+			return nil, err
+		}
+		return staticAPIFactory(client, api), nil
+
+	case AuthModeServiceAccount:
+		return c.getServiceAccountAPIs(credsFile, scope)
+
+	default:
+		return nil, fmt.Errorf("unknown auth mode '%s'", c.AuthMode)
+	}
+}
+
+func staticAPIFactory(client *http.Client, api *calendar.Service) apiFactory {
+	return func(string) (*http.Client, *calendar.Service, error) {
+		return client, api, nil
+	}
+}
+
+// the service account impersonates c.Subject (typically an admin) when set, otherwise it impersonates each user
+// while reading their own calendar
+func (c *CalendarAPI) getServiceAccountAPIs(keyFile string, scope string) (apiFactory, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := google.JWTConfigFromJSON(b, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account key '%s': %s", keyFile, err)
+	}
+
+	if c.Subject != "" {
+		client, api, err := newImpersonatedAPI(config, c.Subject)
+		if err != nil {
+			return nil, err
+		}
+		return staticAPIFactory(client, api), nil
+	}
+
+	mutex := &sync.Mutex{}
+	clients := map[string]*http.Client{}
+	apis := map[string]*calendar.Service{}
+
+	return func(email string) (*http.Client, *calendar.Service, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if api, found := apis[email]; found {
+			return clients[email], api, nil
+		}
+
+		client, api, err := newImpersonatedAPI(config, email)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		clients[email] = client
+		apis[email] = api
+		return client, api, nil
+	}, nil
+}
+
+func newImpersonatedAPI(config *jwt.Config, subject string) (*http.Client, *calendar.Service, error) {
+	impersonated := *config
+	impersonated.Subject = subject
+
+	client := impersonated.Client(context.Background())
+
+	api, err := calendar.New(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, api, nil
+}
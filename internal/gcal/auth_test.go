@@ -0,0 +1,145 @@
+package gcal
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestCalendarAPI_getServiceAccountAPIs(t *testing.T) {
+	scenarios := []struct {
+		desc            string
+		inSubject       string
+		inEmail         string
+		expectedSubject string
+	}{
+		{
+			desc:            "impersonate each user",
+			inEmail:         "foo@example.com",
+			expectedSubject: "foo@example.com",
+		},
+		{
+			desc:            "impersonate the configured admin",
+			inSubject:       "admin@example.com",
+			inEmail:         "foo@example.com",
+			expectedSubject: "admin@example.com",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// inputs
+			var subject string
+
+			// the token is the subject of the assertion so that the API calls show who is impersonated
+			server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				resp.Header().Set("Content-Type", "application/json")
+
+				if req.URL.Path == "/token" {
+					_ = req.ParseForm()
+					_ = json.NewEncoder(resp).Encode(map[string]interface{}{
+						"access_token": assertionSubject(req.PostForm.Get("assertion")),
+						"token_type":   "Bearer",
+						"expires_in":   3600,
+					})
+					return
+				}
+
+				subject = strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+				_, _ = resp.Write([]byte(`{"value": "UTC"}`))
+			}))
+			defer server.Close()
+
+			keyFile, cleanUp := writeTestServiceAccountKey(t, server.URL+"/token")
+			defer cleanUp()
+
+			// call
+			calAPI := &CalendarAPI{AuthMode: AuthModeServiceAccount, Subject: scenario.inSubject}
+			apis, resultErr := calAPI.getAPIs(keyFile, "", calendar.CalendarReadonlyScope)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+
+			_, api, err := apis(scenario.inEmail)
+			assert.Nil(t, err, scenario.desc)
+
+			api.BasePath = server.URL + "/"
+			_, err = api.Settings.Get("timezone").Do()
+			assert.Nil(t, err, scenario.desc)
+			assert.Equal(t, scenario.expectedSubject, subject, scenario.desc)
+		})
+	}
+}
+
+func TestCalendarAPI_getAPIs_unknownMode(t *testing.T) {
+	// call
+	calAPI := &CalendarAPI{AuthMode: "magic"}
+	_, resultErr := calAPI.getAPIs("credentials.json", "token.json", calendar.CalendarReadonlyScope)
+
+	// validate
+	assert.EqualError(t, resultErr, "unknown auth mode 'magic'")
+}
+
+// writes a service account key (with a new private key) that uses the supplied token URL
+func writeTestServiceAccountKey(t *testing.T, tokenURL string) (string, func()) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+
+	key, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "pdgcal@example.iam.gserviceaccount.com",
+		"private_key_id": "1",
+		"private_key":    string(keyPEM),
+		"token_uri":      tokenURL,
+	})
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "gcal")
+	assert.Nil(t, err)
+
+	filename := filepath.Join(dir, "key.json")
+	err = ioutil.WriteFile(filename, key, 0600)
+	assert.Nil(t, err)
+
+	return filename, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// returns the "sub" claim of the (unverified) JWT assertion
+func assertionSubject(assertion string) string {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	claims := map[string]interface{}{}
+	_ = json.Unmarshal(payload, &claims)
+
+	subject, _ := claims["sub"].(string)
+	return subject
+}
@@ -0,0 +1,130 @@
+package gcal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// EventCache is a file based cache of the events of each calendar (keyed by email).
+// Cached calendars are kept up to date using the incremental sync of the events list API.
+// A nil *EventCache is valid and caches nothing.
+type EventCache struct {
+	path string
+
+	mutex     sync.Mutex
+	loaded    bool
+	dirty     bool
+	calendars map[string]*cachedCalendar
+}
+
+type cachedCalendar struct {
+	// SyncToken returns the changes since the events were loaded
+	SyncToken string
+	TimeZone  string
+
+	// From and To are the period that was loaded by the full sync
+	From time.Time
+	To   time.Time
+
+	// Events are mapped by event id
+	Events map[string]*cachedEvent
+}
+
+// googleEvent can not be stored directly as the promoted calendar.Event.MarshalJSON drops the event type
+type cachedEvent struct {
+	Event     *calendar.Event
+	EventType string
+}
+
+// NewEventCache returns a cache stored in the supplied file
+func NewEventCache(path string) *EventCache {
+	return &EventCache{
+		path: path,
+	}
+}
+
+// returns the cached calendar when it covers start to end
+func (c *EventCache) get(email string, start time.Time, end time.Time) *cachedCalendar {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.load()
+
+	calendar, found := c.calendars[email]
+	if !found || calendar.SyncToken == "" || start.Before(calendar.From) || end.After(calendar.To) {
+		return nil
+	}
+
+	return calendar
+}
+
+func (c *EventCache) set(email string, calendar *cachedCalendar) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.load()
+
+	c.calendars[email] = calendar
+	c.dirty = true
+}
+
+// save will write the cache to disk (when it has changed)
+func (c *EventCache) save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	payload, err := json.Marshal(c.calendars)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(c.path, payload, 0600)
+	if err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// load the cache from disk; a missing or corrupt file is treated as an empty cache
+func (c *EventCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.calendars = map[string]*cachedCalendar{}
+
+	payload, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+
+	calendars := map[string]*cachedCalendar{}
+	err = json.Unmarshal(payload, &calendars)
+	if err != nil {
+		return
+	}
+
+	c.calendars = calendars
+}
@@ -0,0 +1,82 @@
+package gcal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// googleEvent adds the fields that are missing from the vendored calendar client
+type googleEvent struct {
+	*calendar.Event
+
+	EventType string `json:"eventType"`
+}
+
+// eventsPage is a response DTO for the events list API
+type eventsPage struct {
+	Items         []*googleEvent `json:"items"`
+	TimeZone      string         `json:"timeZone"`
+	NextPageToken string         `json:"nextPageToken"`
+	NextSyncToken string         `json:"nextSyncToken"`
+}
+
+// calls the events list API directly as the vendored client does not return the event type
+func (c *CalendarAPI) listEvents(client *http.Client, basePath string, calendarID string, params url.Values) (*eventsPage, error) {
+	uri := basePath + "calendars/" + url.PathEscape(calendarID) + "/events?" + params.Encode()
+
+	resp, err := client.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = googleapi.CheckResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &eventsPage{}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// convert the Google event into the provider independent form; returns nil for events without times.
+// All-day events span the whole local day(s) in the event's (or the calendar's) time zone
+func (c *CalendarAPI) toEvent(item *googleEvent, location *time.Location) (*Event, error) {
+	if item.Event == nil || item.Start == nil || item.End == nil {
+		return nil, nil
+	}
+
+	// an event can have its own time zone, otherwise it uses the calendar's
+	startTime, err := c.getTime(item.Start.DateTime, item.Start.Date, LoadLocation(item.Start.TimeZone, location))
+	if err != nil {
+		return nil, err
+	}
+
+	endTime, err := c.getTime(item.End.DateTime, item.End.Date, LoadLocation(item.End.TimeZone, location))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:           item.Id,
+		Summary:      item.Summary,
+		Description:  item.Description,
+		EventType:    item.EventType,
+		Transparency: item.Transparency,
+		Visibility:   item.Visibility,
+		HTMLLink:     item.HtmlLink,
+		Start:        startTime,
+		End:          endTime,
+		AllDay:       item.Start.Date != "",
+	}, nil
+}
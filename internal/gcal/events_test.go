@@ -0,0 +1,463 @@
+package gcal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestCalendarAPI_getCalendar(t *testing.T) {
+	// inputs
+	server := newTestGoogleServer(map[string]*testCalendar{
+		"foo@example.com": {timeZone: "UTC", items: []map[string]interface{}{
+			{
+				"id":      "1",
+				"summary": "Checkout design review",
+				"start":   map[string]string{"dateTime": "2019-01-02T10:00:00Z"},
+				"end":     map[string]string{"dateTime": "2019-01-02T11:00:00Z"},
+			},
+			{
+				"id":        "2",
+				"summary":   "Vacation",
+				"eventType": "outOfOffice",
+				"htmlLink":  "https://www.google.com/calendar/event?eid=2",
+				"start":     map[string]string{"dateTime": "2019-01-03T00:00:00Z"},
+				"end":       map[string]string{"dateTime": "2019-01-05T00:00:00Z"},
+			},
+			{
+				"id":      "3",
+				"summary": "xoncall",
+				"start":   map[string]string{"date": "2019-01-07"},
+				"end":     map[string]string{"date": "2019-01-08"},
+			},
+			{
+				"id":     "4",
+				"status": "cancelled",
+			},
+		}},
+	})
+	defer server.Close()
+
+	client, api := newTestService(t, server)
+	rules := DefaultRules()
+	assert.Nil(t, CompileRules(rules))
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 10)
+
+	// call
+	calAPI := &CalendarAPI{}
+	result := &Calendar{}
+	resultErr := calAPI.getCalendar(client, api, rules, result, "foo@example.com", start, end)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result.Items))
+	if len(result.Items) == 2 {
+		assert.Equal(t, "out of office", result.Items[0].Rule)
+		assert.Equal(t, time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC), result.Items[0].Start.UTC())
+		assert.Equal(t, "Vacation", result.Items[0].Summary)
+		assert.Equal(t, "2", result.Items[0].ID)
+		assert.Equal(t, "https://www.google.com/calendar/event?eid=2", result.Items[0].HTMLLink)
+		assert.Equal(t, "no on-call", result.Items[1].Rule)
+	}
+	assert.Empty(t, result.Warnings)
+}
+
+func TestCalendarAPI_getCalendars_unavailable(t *testing.T) {
+	// inputs
+	server := newTestGoogleServer(map[string]*testCalendar{
+		"foo@example.com": {timeZone: "UTC", items: []map[string]interface{}{
+			{
+				"id":      "1",
+				"summary": "xoncall",
+				"start":   map[string]string{"date": "2019-01-07"},
+				"end":     map[string]string{"date": "2019-01-08"},
+			},
+		}},
+	})
+	defer server.Close()
+
+	apis := staticAPIFactory(newTestService(t, server))
+	rules := DefaultRules()
+	assert.Nil(t, CompileRules(rules))
+
+	users := map[string]string{
+		"FOO": "foo@example.com",
+		"BAR": "bar@personal.example.com",
+	}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// call
+	calAPI := &CalendarAPI{}
+	result, resultErr := calAPI.getCalendars(apis, rules, users, start, start.AddDate(0, 0, 10))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result))
+	assert.Nil(t, result["FOO"].Err)
+	assert.Equal(t, 1, len(result["FOO"].Items))
+	assert.NotNil(t, result["BAR"].Err)
+	assert.Empty(t, result["BAR"].Items)
+}
+
+func TestCalendarAPI_getCalendar_timeZones(t *testing.T) {
+	allDay := func(date string, endDate string) map[string]interface{} {
+		return map[string]interface{}{
+			"summary":   "Out",
+			"eventType": "outOfOffice",
+			"start":     map[string]string{"date": date},
+			"end":       map[string]string{"date": endDate},
+		}
+	}
+	mustLoad := func(name string) *time.Location {
+		location, err := time.LoadLocation(name)
+		assert.Nil(t, err)
+		return location
+	}
+
+	scenarios := []struct {
+		desc          string
+		inTimeZone    string
+		inEvent       map[string]interface{}
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			desc:          "all-day event in Sydney",
+			inTimeZone:    "Australia/Sydney",
+			inEvent:       allDay("2019-01-07", "2019-01-08"),
+			expectedStart: time.Date(2019, 1, 6, 13, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2019, 1, 7, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:          "all-day event in Berlin",
+			inTimeZone:    "Europe/Berlin",
+			inEvent:       allDay("2019-01-07", "2019-01-08"),
+			expectedStart: time.Date(2019, 1, 6, 23, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2019, 1, 7, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:          "DST starts - 23 hour day",
+			inTimeZone:    "Europe/Berlin",
+			inEvent:       allDay("2019-03-31", "2019-04-01"),
+			expectedStart: time.Date(2019, 3, 31, 0, 0, 0, 0, mustLoad("Europe/Berlin")),
+			expectedEnd:   time.Date(2019, 3, 31, 0, 0, 0, 0, mustLoad("Europe/Berlin")).Add(23 * time.Hour),
+		},
+		{
+			desc:          "DST ends - 25 hour day",
+			inTimeZone:    "Europe/Berlin",
+			inEvent:       allDay("2019-10-27", "2019-10-28"),
+			expectedStart: time.Date(2019, 10, 27, 0, 0, 0, 0, mustLoad("Europe/Berlin")),
+			expectedEnd:   time.Date(2019, 10, 27, 0, 0, 0, 0, mustLoad("Europe/Berlin")).Add(25 * time.Hour),
+		},
+		{
+			desc:       "event time zone overrides the calendar's",
+			inTimeZone: "Europe/Berlin",
+			inEvent: map[string]interface{}{
+				"summary": "Out",
+				"start":   map[string]string{"date": "2019-01-07", "timeZone": "America/New_York"},
+				"end":     map[string]string{"date": "2019-01-08", "timeZone": "America/New_York"},
+			},
+			expectedStart: time.Date(2019, 1, 7, 5, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2019, 1, 8, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:       "timed event is not affected by the time zone",
+			inTimeZone: "Australia/Sydney",
+			inEvent: map[string]interface{}{
+				"summary": "Out",
+				"start":   map[string]string{"dateTime": "2019-01-07T09:00:00-05:00"},
+				"end":     map[string]string{"dateTime": "2019-01-07T17:00:00-05:00"},
+			},
+			expectedStart: time.Date(2019, 1, 7, 14, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2019, 1, 7, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:          "unknown calendar time zone falls back to UTC",
+			inTimeZone:    "Mars/Olympus_Mons",
+			inEvent:       allDay("2019-01-07", "2019-01-08"),
+			expectedStart: time.Date(2019, 1, 7, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2019, 1, 8, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			server := newTestGoogleServer(map[string]*testCalendar{
+				"foo@example.com": {timeZone: scenario.inTimeZone, items: []map[string]interface{}{scenario.inEvent}},
+			})
+			defer server.Close()
+
+			client, api := newTestService(t, server)
+			rules := DefaultRules()
+			assert.Nil(t, CompileRules(rules))
+
+			start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			// call
+			calAPI := &CalendarAPI{}
+			result := &Calendar{}
+			resultErr := calAPI.getCalendar(client, api, rules, result, "foo@example.com", start, start.AddDate(1, 0, 0))
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, 1, len(result.Items), scenario.desc)
+			if len(result.Items) == 1 {
+				assert.True(t, scenario.expectedStart.Equal(result.Items[0].Start), scenario.desc)
+				assert.True(t, scenario.expectedEnd.Equal(result.Items[0].End), scenario.desc)
+			}
+		})
+	}
+}
+
+func TestCalendarAPI_getCalendar_paging(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	newEvents := func(count int) []map[string]interface{} {
+		var out []map[string]interface{}
+		for x := 0; x < count; x++ {
+			eventStart := start.Add(time.Duration(x) * time.Hour)
+			out = append(out, map[string]interface{}{
+				"id":      strconv.Itoa(x),
+				"summary": "xoncall",
+				"start":   map[string]string{"dateTime": eventStart.Format(time.RFC3339)},
+				"end":     map[string]string{"dateTime": eventStart.Add(time.Hour).Format(time.RFC3339)},
+			})
+		}
+		return out
+	}
+
+	scenarios := []struct {
+		desc             string
+		inEvents         int
+		expectedItems    int
+		expectedWarnings int
+	}{
+		{
+			desc:             "all pages are loaded",
+			inEvents:         7,
+			expectedItems:    7,
+			expectedWarnings: 0,
+		},
+		{
+			desc:             "too many matching events",
+			inEvents:         manyEventsWarning + 1,
+			expectedItems:    manyEventsWarning + 1,
+			expectedWarnings: 1,
+		},
+		{
+			desc:             "too many pages",
+			inEvents:         (maxEventPages + 1) * testPageSize,
+			expectedItems:    maxEventPages * testPageSize,
+			expectedWarnings: 2,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			server := newTestGoogleServer(map[string]*testCalendar{
+				"foo@example.com": {timeZone: "UTC", items: newEvents(scenario.inEvents)},
+			})
+			defer server.Close()
+
+			client, api := newTestService(t, server)
+			rules := DefaultRules()
+			assert.Nil(t, CompileRules(rules))
+
+			// call
+			calAPI := &CalendarAPI{}
+			result := &Calendar{}
+			resultErr := calAPI.getCalendar(client, api, rules, result, "foo@example.com", start, start.AddDate(0, 0, 30))
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedItems, len(result.Items), scenario.desc)
+			assert.Equal(t, scenario.expectedWarnings, len(result.Warnings), scenario.desc)
+		})
+	}
+}
+
+func TestCalendarAPI_getCalendar_cache(t *testing.T) {
+	// inputs
+	calendar := &testCalendar{
+		timeZone:  "UTC",
+		syncToken: "token-1",
+		items: []map[string]interface{}{
+			{
+				"id":        "1",
+				"summary":   "Vacation",
+				"eventType": "outOfOffice",
+				"start":     map[string]string{"dateTime": "2019-01-03T00:00:00Z"},
+				"end":       map[string]string{"dateTime": "2019-01-05T00:00:00Z"},
+			},
+			{
+				"id":      "2",
+				"summary": "xoncall",
+				"start":   map[string]string{"date": "2019-01-07"},
+				"end":     map[string]string{"date": "2019-01-08"},
+			},
+		},
+	}
+	server := newTestGoogleServer(map[string]*testCalendar{"foo@example.com": calendar})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cacheFile := filepath.Join(dir, "events.json")
+
+	client, api := newTestService(t, server)
+	rules := DefaultRules()
+	assert.Nil(t, CompileRules(rules))
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 10)
+
+	// each run uses a new cache loaded from the file
+	run := func() []string {
+		calAPI := &CalendarAPI{Cache: NewEventCache(cacheFile)}
+		result := &Calendar{}
+		resultErr := calAPI.getCalendar(client, api, rules, result, "foo@example.com", start, end)
+		assert.Nil(t, resultErr)
+		assert.Nil(t, calAPI.Cache.save())
+
+		var out []string
+		for _, item := range result.Items {
+			out = append(out, item.Rule+" "+item.Start.UTC().Format("2006-01-02"))
+		}
+		return out
+	}
+
+	// call - first run is a full sync
+	result := run()
+
+	// validate
+	assert.Equal(t, []string{"out of office 2019-01-03", "no on-call 2019-01-07"}, result)
+	assert.Equal(t, 1, calendar.fullSyncs)
+
+	// call - the next run only loads the changes
+	calendar.changes = []map[string]interface{}{
+		{"id": "1", "status": "cancelled"},
+		{
+			"id":      "3",
+			"summary": "xoncall",
+			"start":   map[string]string{"date": "2019-01-09"},
+			"end":     map[string]string{"date": "2019-01-10"},
+		},
+	}
+	result = run()
+
+	// validate
+	assert.Equal(t, []string{"no on-call 2019-01-07", "no on-call 2019-01-09"}, result)
+	assert.Equal(t, 1, calendar.fullSyncs)
+	assert.Equal(t, 1, calendar.incrementalSyncs)
+
+	// call - expired sync token (410 Gone) causes a full sync
+	calendar.syncToken = "token-2"
+	result = run()
+
+	// validate
+	assert.Equal(t, []string{"out of office 2019-01-03", "no on-call 2019-01-07"}, result)
+	assert.Equal(t, 2, calendar.fullSyncs)
+	assert.Equal(t, 2, calendar.incrementalSyncs)
+
+	// call - a period that is not cached causes a full sync
+	start = start.AddDate(0, 3, 0)
+	end = start.AddDate(0, 0, 10)
+	result = run()
+
+	// validate
+	assert.Empty(t, result)
+	assert.Equal(t, 3, calendar.fullSyncs)
+	assert.Equal(t, 2, calendar.incrementalSyncs)
+}
+
+const testPageSize = 5
+
+// testCalendar is a calendar served by the fake Google Calendar API
+type testCalendar struct {
+	timeZone string
+	items    []map[string]interface{}
+
+	// syncToken is returned by full syncs (when set); incremental syncs with it return the changes
+	syncToken string
+	changes   []map[string]interface{}
+
+	fullSyncs        int
+	incrementalSyncs int
+}
+
+// returns a fake version of the Google Calendar API that serves the supplied calendars (mapped by calendar id)
+func newTestGoogleServer(calendars map[string]*testCalendar) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Content-Type", "application/json")
+
+		calendarID := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/calendars/"), "/events")
+		calendar, found := calendars[calendarID]
+		if !found {
+			resp.WriteHeader(http.StatusNotFound)
+			_, _ = resp.Write([]byte(`{"error": {"code": 404, "message": "Not Found"}}`))
+			return
+		}
+
+		if syncToken := req.URL.Query().Get("syncToken"); syncToken != "" {
+			calendar.incrementalSyncs++
+			if syncToken != calendar.syncToken {
+				resp.WriteHeader(http.StatusGone)
+				_, _ = resp.Write([]byte(`{"error": {"code": 410, "message": "Sync token is no longer valid, a full sync is required."}}`))
+				return
+			}
+
+			_ = json.NewEncoder(resp).Encode(map[string]interface{}{
+				"timeZone":      calendar.timeZone,
+				"items":         calendar.changes,
+				"nextSyncToken": calendar.syncToken,
+			})
+			return
+		}
+
+		if req.URL.Query().Get("pageToken") == "" {
+			calendar.fullSyncs++
+		}
+
+		// return small pages to exercise the paging
+		items := calendar.items
+		offset, _ := strconv.Atoi(req.URL.Query().Get("pageToken"))
+		page := map[string]interface{}{
+			"timeZone": calendar.timeZone,
+		}
+		if offset+testPageSize < len(items) {
+			page["items"] = items[offset : offset+testPageSize]
+			page["nextPageToken"] = strconv.Itoa(offset + testPageSize)
+		} else {
+			page["items"] = items[offset:]
+			if calendar.syncToken != "" {
+				page["nextSyncToken"] = calendar.syncToken
+			}
+		}
+
+		_ = json.NewEncoder(resp).Encode(page)
+	}))
+}
+
+func newTestService(t *testing.T, server *httptest.Server) (*http.Client, *calendar.Service) {
+	client := server.Client()
+
+	api, err := calendar.New(client)
+	assert.Nil(t, err)
+	api.BasePath = server.URL + "/"
+
+	return client, api
+}
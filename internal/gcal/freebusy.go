@@ -0,0 +1,147 @@
+package gcal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+const (
+	// SourceEvents finds unavailability by matching the user's calendar events against the rules
+	SourceEvents = "events"
+
+	// SourceFreeBusy finds unavailability from the user's busy blocks; this works with private calendars but the
+	// event details are not available so the rules are not used
+	SourceFreeBusy = "freebusy"
+
+	// the maximum number of calendars the FreeBusy API accepts per request
+	freeBusyBatchSize = 50
+
+	// all-day events are at least this long (allowing for daylight saving changes)
+	allDayMinimum = 23 * time.Hour
+)
+
+// FreeBusyOptions controls which busy blocks are treated as unavailability.
+// When neither option is set every busy block is treated as unavailability
+type FreeBusyOptions struct {
+	// AllDay treats blocks that cover a whole day as unavailability
+	AllDay bool
+
+	// MinDuration treats blocks that are at least this long as unavailability (0 disables this)
+	MinDuration time.Duration
+}
+
+// returns the rule name to record against the block or "" when the block should be ignored
+func (o FreeBusyOptions) match(start time.Time, end time.Time) string {
+	duration := end.Sub(start)
+
+	if !o.AllDay && o.MinDuration == 0 {
+		return "busy"
+	}
+
+	if o.AllDay && duration >= allDayMinimum {
+		return "busy (all day)"
+	}
+
+	if o.MinDuration > 0 && duration >= o.MinDuration {
+		return "busy"
+	}
+
+	return ""
+}
+
+// load the busy blocks for all of the users using as few requests as possible
+func (c *CalendarAPI) getFreeBusyCalendars(apis apiFactory, users map[string]string, start time.Time, end time.Time) (map[string]*Calendar, error) {
+	// sort to make the batches predictable
+	var ids []string
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := map[string]*Calendar{}
+
+	for len(ids) > 0 {
+		batchSize := freeBusyBatchSize
+		if len(ids) < batchSize {
+			batchSize = len(ids)
+		}
+
+		err := c.getFreeBusyBatch(apis, ids[:batchSize], users, out, start, end)
+		if isInvalidGrant(err) {
+			return nil, err
+		}
+		if err != nil {
+			// carry on with the other batches; the availability of these users is unknown
+			for _, id := range ids[:batchSize] {
+				out[id] = &Calendar{Err: err}
+			}
+		}
+
+		ids = ids[batchSize:]
+	}
+
+	return out, nil
+}
+
+func (c *CalendarAPI) getFreeBusyBatch(apis apiFactory, ids []string, users map[string]string, out map[string]*Calendar, start time.Time, end time.Time) error {
+	// when impersonating each user, query as the first user in the batch (any user in the domain can see free/busy)
+	_, api, err := apis(users[ids[0]])
+	if err != nil {
+		return err
+	}
+
+	request := &calendar.FreeBusyRequest{
+		TimeMin: start.AddDate(0, 0, -1).Format(time.RFC3339),
+		TimeMax: end.AddDate(0, 0, 1).Format(time.RFC3339),
+	}
+	for _, id := range ids {
+		request.Items = append(request.Items, &calendar.FreeBusyRequestItem{Id: users[id]})
+	}
+
+	resp, err := api.Freebusy.Query(request).Do()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		email := users[id]
+
+		result, found := resp.Calendars[email]
+		if !found {
+			out[id] = &Calendar{Err: fmt.Errorf("no free/busy returned for '%s'", email)}
+			continue
+		}
+
+		if len(result.Errors) > 0 {
+			out[id] = &Calendar{Err: fmt.Errorf("failed to load free/busy for '%s': %s", email, result.Errors[0].Reason)}
+			continue
+		}
+
+		calendar := &Calendar{}
+		for _, busy := range result.Busy {
+			blockStart, err := time.Parse(time.RFC3339, busy.Start)
+			if err != nil {
+				return err
+			}
+
+			blockEnd, err := time.Parse(time.RFC3339, busy.End)
+			if err != nil {
+				return err
+			}
+
+			rule := c.FreeBusy.match(blockStart, blockEnd)
+			if rule == "" {
+				continue
+			}
+
+			calendar.Items = append(calendar.Items, &CalendarItem{Start: blockStart, End: blockEnd, Rule: rule})
+		}
+
+		out[id] = calendar
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package gcal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestFreeBusyOptions_match(t *testing.T) {
+	start := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	scenarios := []struct {
+		desc         string
+		inOptions    FreeBusyOptions
+		inDuration   time.Duration
+		expectedRule string
+	}{
+		{
+			desc:         "no options - every block",
+			inDuration:   time.Hour,
+			expectedRule: "busy",
+		},
+		{
+			desc:         "all day - meeting is ignored",
+			inOptions:    FreeBusyOptions{AllDay: true},
+			inDuration:   time.Hour,
+			expectedRule: "",
+		},
+		{
+			desc:         "all day - whole day",
+			inOptions:    FreeBusyOptions{AllDay: true},
+			inDuration:   24 * time.Hour,
+			expectedRule: "busy (all day)",
+		},
+		{
+			desc:         "all day - daylight saving day",
+			inOptions:    FreeBusyOptions{AllDay: true},
+			inDuration:   23 * time.Hour,
+			expectedRule: "busy (all day)",
+		},
+		{
+			desc:         "minimum duration - long block",
+			inOptions:    FreeBusyOptions{AllDay: true, MinDuration: 4 * time.Hour},
+			inDuration:   5 * time.Hour,
+			expectedRule: "busy",
+		},
+		{
+			desc:         "minimum duration - short block",
+			inOptions:    FreeBusyOptions{MinDuration: 4 * time.Hour},
+			inDuration:   3 * time.Hour,
+			expectedRule: "",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			result := scenario.inOptions.match(start, start.Add(scenario.inDuration))
+
+			// validate
+			assert.Equal(t, scenario.expectedRule, result, scenario.desc)
+		})
+	}
+}
+
+func TestCalendarAPI_getFreeBusyCalendars(t *testing.T) {
+	// inputs
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		request := &calendar.FreeBusyRequest{}
+		_ = json.NewDecoder(req.Body).Decode(request)
+
+		result := &calendar.FreeBusyResponse{Calendars: map[string]calendar.FreeBusyCalendar{}}
+		for _, item := range request.Items {
+			result.Calendars[item.Id] = calendar.FreeBusyCalendar{
+				Busy: []*calendar.TimePeriod{
+					{Start: "2019-01-02T09:00:00Z", End: "2019-01-02T10:00:00Z"},
+					{Start: "2019-01-03T00:00:00Z", End: "2019-01-04T00:00:00Z"},
+				},
+			}
+		}
+
+		resp.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(resp).Encode(result)
+	}))
+	defer server.Close()
+
+	apis := staticAPIFactory(newTestService(t, server))
+
+	users := map[string]string{}
+	for x := 0; x < 60; x++ {
+		users[fmt.Sprintf("USER%02d", x)] = fmt.Sprintf("user%02d@example.com", x)
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 10)
+
+	// call
+	calAPI := &CalendarAPI{Source: SourceFreeBusy, FreeBusy: FreeBusyOptions{AllDay: true}}
+	result, resultErr := calAPI.getFreeBusyCalendars(apis, users, start, end)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, 60, len(result))
+	assert.Equal(t, 1, len(result["USER00"].Items))
+	assert.Equal(t, "busy (all day)", result["USER59"].Items[0].Rule)
+}
+
+func TestCalendarAPI_getFreeBusyCalendars_calendarError(t *testing.T) {
+	// inputs
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Content-Type", "application/json")
+		_, _ = resp.Write([]byte(`{"calendars": {"foo@example.com": {"errors": [{"domain": "global", "reason": "notFound"}]}}}`))
+	}))
+	defer server.Close()
+
+	apis := staticAPIFactory(newTestService(t, server))
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// call
+	calAPI := &CalendarAPI{Source: SourceFreeBusy}
+	result, resultErr := calAPI.getFreeBusyCalendars(apis, map[string]string{"FOO": "foo@example.com", "BAR": "bar@example.com"}, start, start.AddDate(0, 0, 1))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.EqualError(t, result["FOO"].Err, "failed to load free/busy for 'foo@example.com': notFound")
+	assert.EqualError(t, result["BAR"].Err, "no free/busy returned for 'bar@example.com'")
+}
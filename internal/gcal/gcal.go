@@ -2,111 +2,391 @@ package gcal
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"sort"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// guard against paging forever through a huge (or broken) calendar
+	maxEventPages = 50
+
+	// more matching events than this for one user probably means that a rule is too broad
+	manyEventsWarning = 100
+
+	// days past the period loaded into the EventCache
+	cacheDays = 30
+
+	// the number of calendars loaded at the same time
+	defaultConcurrency = 5
 )
 
 // CalendarItem is an output DTO
 type CalendarItem struct {
 	Start time.Time
 	End   time.Time
+
+	// Rule is the name of the rule that matched the event
+	Rule string
+
+	// Summary, ID and HTMLLink identify the event (when the source provides them)
+	Summary  string
+	ID       string
+	HTMLLink string
 }
 
 // Calendar is an output DTO
 type Calendar struct {
 	Items []*CalendarItem
+
+	// Warnings are problems found while loading the calendar that did not stop it from loading
+	Warnings []string
+
+	// Err is set when the calendar could not be loaded (e.g. it is not shared); the user's availability is unknown
+	Err error
+
+	// TimeZone is the IANA time zone of the calendar; empty when the source does not provide one
+	TimeZone string
 }
 
 // CalendarAPI contains the functions to call the calendar APIs
-type CalendarAPI struct{}
+type CalendarAPI struct {
+	// Source is either SourceEvents (default) or SourceFreeBusy
+	Source string
+
+	// Rules decide which events mean the user is unavailable (default: DefaultRules()); only used by SourceEvents
+	Rules []*Rule
+
+	// FreeBusy decides which busy blocks mean the user is unavailable; only used by SourceFreeBusy
+	FreeBusy FreeBusyOptions
+
+	// AuthMode is either AuthModeOAuth (default) or AuthModeServiceAccount
+	AuthMode string
+
+	// Subject is the user the service account impersonates; when empty each user's calendar is read as that user.
+	// Only used by AuthModeServiceAccount
+	Subject string
+
+	// Tokens stores the OAuth token (default: a FileTokenStore using the tokenFile); only used by AuthModeOAuth
+	Tokens TokenStore
+
+	// Reauthenticate is asked whether to sign in again when the saved token has expired or been revoked.
+	// When nil (or it returns false) ErrInvalidGrant is returned instead
+	Reauthenticate func() bool
+
+	// Concurrency is the number of calendars loaded at the same time (default: 5)
+	Concurrency int
+
+	// Cache keeps the events between runs so that only the changes are loaded (default: no cache);
+	// only used by SourceEvents
+	Cache *EventCache
 
-// GetCalendars returns the calendars for the emails (map values) provided
+	// openBrowser is passed to the loopbackFlow (used in tests)
+	openBrowser func(url string) error
+}
+
+// GetCalendars returns the calendars for the emails (map values) provided.
+// A calendar that could not be loaded is returned with Err set rather than failing the other users.
+// With AuthModeServiceAccount the credentialsFile is the service account key and the tokenFile is not used
 func (c *CalendarAPI) GetCalendars(credentialsFile, tokenFile string, users map[string]string, start time.Time, end time.Time) (map[string]*Calendar, error) {
-	api, err := c.getAPI(credentialsFile, tokenFile)
+	if c.Source != "" && c.Source != SourceEvents && c.Source != SourceFreeBusy {
+		return nil, fmt.Errorf("unknown calendar source '%s'", c.Source)
+	}
+
+	rules := c.Rules
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	err := CompileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	apis, err := c.getAPIs(credentialsFile, tokenFile, calendar.CalendarReadonlyScope)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := c.getCalendars(apis, rules, users, start, end)
+	if isInvalidGrant(err) {
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Cache.save()
 	if err != nil {
 		return nil, err
 	}
 
+	return out, nil
+}
+
+func (c *CalendarAPI) getCalendars(apis apiFactory, rules []*Rule, users map[string]string, start time.Time, end time.Time) (map[string]*Calendar, error) {
+	if c.Source == SourceFreeBusy {
+		return c.getFreeBusyCalendars(apis, users, start, end)
+	}
+
 	out := map[string]*Calendar{}
+	var fatalErr error
+	mutex := &sync.Mutex{}
 
-	for id, email := range users {
+	c.forEachUser(users, func(id string, email string) {
 		calendar := &Calendar{}
 
-		// check for "Out of Office" events
-		err = c.getCalendar(api, calendar, "out", email, start, end)
-		if err != nil {
-			return nil, err
+		client, api, err := apis(email)
+		if err == nil {
+			err = c.getCalendar(client, api, rules, calendar, email, start, end)
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if isInvalidGrant(err) {
+			// the sign in is broken for everyone
+			fatalErr = err
+			return
 		}
 
-		// check for "No On-Call" events
-		err = c.getCalendar(api, calendar, "xoncall", email, start, end)
 		if err != nil {
-			return nil, err
+			// carry on with the other users; this user's availability is unknown
+			calendar = &Calendar{Err: err}
 		}
 
 		out[id] = calendar
+	})
+	if fatalErr != nil {
+		return nil, fatalErr
 	}
 
 	return out, nil
 }
 
-// will return the calendar for the supplied email address
-// (taken from API example)
-func (c *CalendarAPI) getCalendar(api *calendar.Service, out *Calendar, searchTerm string, email string, start time.Time, end time.Time) error {
-	settings, err := api.Settings.Get("timezone").Do()
+// calls fn for each user (id and email) using a bounded pool of workers
+func (c *CalendarAPI) forEachUser(users map[string]string, fn func(id string, email string)) {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	queue := make(chan string)
+	wg := &sync.WaitGroup{}
+
+	for x := 0; x < concurrency; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for id := range queue {
+				fn(id, users[id])
+			}
+		}()
+	}
+
+	for id := range users {
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// will add the events from the calendar for the supplied email address that match any of the rules
+func (c *CalendarAPI) getCalendar(client *http.Client, api *calendar.Service, rules []*Rule, out *Calendar, email string, start time.Time, end time.Time) error {
+	// Expand the search to ensure we get everything
+	from := start.AddDate(0, 0, -1)
+	to := end.AddDate(0, 0, 1)
+
+	items, timeZone, err := c.loadEvents(client, api.BasePath, out, email, from, to)
 	if err != nil {
 		return err
 	}
 
-	userTimezone := settings.Value
-	location, err := time.LoadLocation(userTimezone)
+	// all-day events are in the time zone of the calendar (not of the user running this app)
+	location := LoadLocation(timeZone, time.UTC)
+	out.TimeZone = timeZone
+
+	for _, item := range items {
+		event, err := c.toEvent(item, location)
+		if err != nil {
+			return err
+		}
+
+		// cached calendars also contain events outside of the period
+		if event == nil || !event.Start.Before(to) || !event.End.After(from) {
+			continue
+		}
+
+		rule := MatchRule(rules, event)
+		if rule == nil {
+			continue
+		}
+
+		out.Items = append(out.Items, NewCalendarItem(event, rule))
+	}
+
+	sort.SliceStable(out.Items, func(i, j int) bool {
+		return out.Items[i].Start.Before(out.Items[j].Start)
+	})
+
+	if len(out.Items) > manyEventsWarning {
+		out.Warnings = append(out.Warnings, fmt.Sprintf("found %d matching events for '%s'; check that the calendar rules are not too broad", len(out.Items), email))
+	}
+
+	return nil
+}
+
+// returns the events between from and to along with the calendar's time zone.
+// When the calendar is cached only the changes since the last run are loaded
+func (c *CalendarAPI) loadEvents(client *http.Client, basePath string, out *Calendar, email string, from time.Time, to time.Time) ([]*googleEvent, string, error) {
+	cached := c.Cache.get(email, from, to)
+	if cached != nil {
+		items, err := c.syncEvents(client, basePath, out, email, cached)
+		if !isSyncTokenExpired(err) {
+			return items, cached.TimeZone, err
+		}
+		// the sync token is no longer valid; start again with a full sync
+	}
+
+	if c.Cache != nil {
+		// load further ahead so that the following (daily) runs can use the incremental sync
+		to = to.AddDate(0, 0, cacheDays)
+	}
+
+	params := eventParams()
+	params.Set("timeMin", from.Format(time.RFC3339))
+	params.Set("timeMax", to.Format(time.RFC3339))
+
+	events, err := c.listAllEvents(client, basePath, out, email, params)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	events, err := api.Events.List(email).
-		AlwaysIncludeEmail(false).
-		ShowDeleted(false).
-		SingleEvents(true).
-		// Expand the search to ensure we get everything
-		TimeMin(start.AddDate(0, 0, -1).Format(time.RFC3339)).
-		TimeMax(end.AddDate(0, 0, 1).Format(time.RFC3339)).
-		MaxResults(100).
-		Q(searchTerm).
-		Do()
+	// without the sync token (e.g. too many pages) the calendar is loaded in full next time
+	if events.NextSyncToken != "" {
+		calendar := &cachedCalendar{
+			SyncToken: events.NextSyncToken,
+			TimeZone:  events.TimeZone,
+			From:      from,
+			To:        to,
+			Events:    map[string]*cachedEvent{},
+		}
+		for _, item := range events.Items {
+			if item.Event != nil {
+				calendar.Events[item.Id] = &cachedEvent{Event: item.Event, EventType: item.EventType}
+			}
+		}
+		c.Cache.set(email, calendar)
+	}
+
+	return events.Items, events.TimeZone, nil
+}
+
+// apply the changes since the last run to the cached calendar and return all of its events
+func (c *CalendarAPI) syncEvents(client *http.Client, basePath string, out *Calendar, email string, cached *cachedCalendar) ([]*googleEvent, error) {
+	params := eventParams()
+	params.Set("syncToken", cached.SyncToken)
 
+	changes, err := c.listAllEvents(client, basePath, out, email, params)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if len(events.Items) == 0 {
-		return nil
+	for _, item := range changes.Items {
+		if item.Event == nil {
+			continue
+		}
+
+		if item.Status == "cancelled" {
+			delete(cached.Events, item.Id)
+			continue
+		}
+
+		cached.Events[item.Id] = &cachedEvent{Event: item.Event, EventType: item.EventType}
 	}
 
-	for _, item := range events.Items {
-		startTime, err := c.getTime(item.Start.DateTime, item.Start.Date, location)
-		if err != nil {
-			return err
+	if changes.TimeZone != "" {
+		cached.TimeZone = changes.TimeZone
+	}
+	cached.SyncToken = changes.NextSyncToken
+	c.Cache.set(email, cached)
+
+	items := make([]*googleEvent, 0, len(cached.Events))
+	for _, event := range cached.Events {
+		items = append(items, &googleEvent{Event: event.Event, EventType: event.EventType})
+	}
+
+	return items, nil
+}
+
+// the parameters must be the same for the full and the incremental sync (other than the period)
+func eventParams() url.Values {
+	params := url.Values{}
+	params.Set("alwaysIncludeEmail", "false")
+	params.Set("showDeleted", "false")
+	params.Set("singleEvents", "true")
+	params.Set("maxResults", "250")
+	return params
+}
+
+// loads all pages of events; the time zone is from the first page and the sync token from the last
+func (c *CalendarAPI) listAllEvents(client *http.Client, basePath string, out *Calendar, email string, params url.Values) (*eventsPage, error) {
+	result := &eventsPage{}
+
+	for page := 0; ; page++ {
+		if page == maxEventPages {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("stopped loading events for '%s' after %d pages; some events may be missing", email, maxEventPages))
+			return result, nil
 		}
 
-		endTime, err := c.getTime(item.End.DateTime, item.End.Date, location)
+		events, err := c.listEvents(client, basePath, email, params)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		out.Items = append(out.Items, &CalendarItem{Start: startTime, End: endTime})
+		if page == 0 {
+			result.TimeZone = events.TimeZone
+		}
+		result.Items = append(result.Items, events.Items...)
+
+		if events.NextPageToken == "" {
+			result.NextSyncToken = events.NextSyncToken
+			return result, nil
+		}
+		params.Set("pageToken", events.NextPageToken)
 	}
+}
 
-	return nil
+// returns true when Google requires a full sync (410 Gone)
+func isSyncTokenExpired(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusGone
+}
+
+// LoadLocation returns the named location or the fallback when the name is empty or unknown (e.g. a Windows time zone
+// name)
+func LoadLocation(name string, fallback *time.Location) *time.Location {
+	if name == "" {
+		return fallback
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return fallback
+	}
+
+	return location
 }
 
 func (x *CalendarAPI) getTime(dateTime string, date string, location *time.Location) (time.Time, error) {
@@ -127,72 +407,74 @@ func (x *CalendarAPI) getTime(dateTime string, date string, location *time.Locat
 	return out, nil
 }
 
-func (c *CalendarAPI) getAPI(credsFile, tokFile string) (*calendar.Service, error) {
+func (c *CalendarAPI) getAPI(credsFile string, tokens TokenStore, scope string) (*http.Client, *calendar.Service, error) {
 	b, err := ioutil.ReadFile(credsFile)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, calendar.CalendarReadonlyScope)
+	// If modifying these scopes, delete your previously saved token.
+	config, err := google.ConfigFromJSON(b, scope)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	client, err := c.getClient(tokens, config)
+	if err != nil {
+		return nil, nil, err
 	}
-	client := getClient(tokFile, config)
-
-	return calendar.New(client)
-}
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(tokFile string, config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tok, err := tokenFromFile(tokFile)
+	api, err := calendar.New(client)
 	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
+		return nil, nil, err
 	}
-	return config.Client(context.Background(), tok)
+
+	return client, api, nil
 }
 
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Fprintf(os.Stderr, "Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
+// Retrieve a token (signing in when there is none), then returns the generated client.
+func (c *CalendarAPI) getClient(tokens TokenStore, config *oauth2.Config) (*http.Client, error) {
+	ctx := context.Background()
 
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code: %v", err)
+	tok, err := tokens.Load()
+	if err == ErrNoToken {
+		tok, err = c.signIn(ctx, tokens, config)
 	}
-
-	tok, err := config.Exchange(context.TODO(), authCode)
 	if err != nil {
-		log.Fatalf("Unable to retrieve token from web: %v", err)
+		return nil, err
 	}
-	return tok
+
+	// refresh now (when required) so that a revoked token is found before loading any calendars
+	source := config.TokenSource(ctx, tok)
+	_, err = source.Token()
+	if isInvalidGrant(err) {
+		if c.Reauthenticate == nil || !c.Reauthenticate() {
+			return nil, ErrInvalidGrant
+		}
+
+		tok, err = c.signIn(ctx, tokens, config)
+		if err != nil {
+			return nil, err
+		}
+		source = config.TokenSource(ctx, tok)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return oauth2.NewClient(ctx, source), nil
 }
 
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
+// run the OAuth flow in the browser and save the resulting token
+func (c *CalendarAPI) signIn(ctx context.Context, tokens TokenStore, config *oauth2.Config) (*oauth2.Token, error) {
+	tok, err := (&loopbackFlow{config: config, openBrowser: c.openBrowser}).getToken(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
-}
 
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	err = tokens.Save(tok)
 	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+		// the token can still be used for this run
+		fmt.Printf("WARNING: failed to save the Google token: %s\n", err)
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+
+	return tok, nil
 }
@@ -0,0 +1,193 @@
+package gcal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// how long to wait for the user to complete the sign in
+	defaultLoopbackTimeout = 5 * time.Minute
+)
+
+// loopbackFlow is the OAuth flow for installed apps; the browser is redirected to a listener on a random local port
+// (see https://developers.google.com/identity/protocols/oauth2/native-app)
+type loopbackFlow struct {
+	config *oauth2.Config
+
+	// openBrowser opens the supplied URL (defaults to the system browser)
+	openBrowser func(url string) error
+
+	// timeout is how long to wait for the redirect (defaults to defaultLoopbackTimeout)
+	timeout time.Duration
+
+	// out receives the instructions for the user (defaults to stderr)
+	out io.Writer
+}
+
+// result of the redirect
+type loopbackResult struct {
+	code string
+	err  error
+}
+
+// getToken runs the flow and returns the token
+func (l *loopbackFlow) getToken(ctx context.Context) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the local listener for the OAuth redirect: %s", err)
+	}
+
+	config := *l.config
+	config.RedirectURL = "http://" + listener.Addr().String() + "/"
+
+	state, err := randomString()
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	verifier, err := randomString()
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	results := make(chan *loopbackResult, 1)
+	server := &http.Server{Handler: l.handler(state, results)}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	authURL := config.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	fmt.Fprintf(l.output(), "Sign in to Google Calendar using the following link (if the browser does not open automatically):\n%s\n", authURL)
+	err = l.browser()(authURL)
+	if err != nil {
+		fmt.Fprintf(l.output(), "failed to open the browser: %s\n", err)
+	}
+
+	timeout := l.timeout
+	if timeout == 0 {
+		timeout = defaultLoopbackTimeout
+	}
+
+	var result *loopbackResult
+	select {
+	case result = <-results:
+	case <-time.After(timeout):
+		return nil, errors.New("timed out waiting for the Google sign in to complete")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	token, err := config.Exchange(ctx, result.code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange the authorization code: %s", err)
+	}
+
+	return token, nil
+}
+
+// handles the redirect from the authorization server; only the first redirect is used
+func (l *loopbackFlow) handler(state string, results chan<- *loopbackResult) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(resp, req)
+			return
+		}
+
+		query := req.URL.Query()
+		result := &loopbackResult{}
+
+		switch {
+		case query.Get("state") != state:
+			result.err = errors.New("the OAuth redirect had an invalid state; please try again")
+
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("the Google sign in failed: %s", query.Get("error"))
+
+		case query.Get("code") == "":
+			result.err = errors.New("the OAuth redirect did not include an authorization code")
+
+		default:
+			result.code = query.Get("code")
+		}
+
+		if result.err != nil {
+			http.Error(resp, result.err.Error(), http.StatusBadRequest)
+		} else {
+			_, _ = resp.Write([]byte("Sign in complete; you can close this window and return to the terminal.\n"))
+		}
+
+		select {
+		case results <- result:
+		default:
+			// already have a result
+		}
+	})
+}
+
+func (l *loopbackFlow) output() io.Writer {
+	if l.out == nil {
+		return os.Stderr
+	}
+	return l.out
+}
+
+func (l *loopbackFlow) browser() func(string) error {
+	if l.openBrowser == nil {
+		return openSystemBrowser
+	}
+	return l.openBrowser
+}
+
+func openSystemBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+// returns a URL safe random string with 256 bits of entropy
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// returns the S256 PKCE challenge for the verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,161 @@
+package gcal
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestLoopbackFlow_getToken(t *testing.T) {
+	scenarios := []struct {
+		desc          string
+		inRedirect    func(query url.Values)
+		inTimeout     time.Duration
+		expectedToken string
+		expectedErr   string
+	}{
+		{
+			desc:          "happy path",
+			inRedirect:    func(query url.Values) {},
+			expectedToken: "access-token",
+		},
+		{
+			desc: "invalid state",
+			inRedirect: func(query url.Values) {
+				query.Set("state", "forged")
+			},
+			expectedErr: "the OAuth redirect had an invalid state; please try again",
+		},
+		{
+			desc: "user denied access",
+			inRedirect: func(query url.Values) {
+				query.Del("code")
+				query.Set("error", "access_denied")
+			},
+			expectedErr: "the Google sign in failed: access_denied",
+		},
+		{
+			desc:        "user never completes the sign in",
+			inRedirect:  nil,
+			inTimeout:   50 * time.Millisecond,
+			expectedErr: "timed out waiting for the Google sign in to complete",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// inputs
+			authServer := newTestAuthServer(t)
+			defer authServer.Close()
+
+			flow := &loopbackFlow{
+				config: &oauth2.Config{
+					ClientID:     "client",
+					ClientSecret: "secret",
+					Endpoint: oauth2.Endpoint{
+						AuthURL:  authServer.URL + "/auth",
+						TokenURL: authServer.URL + "/token",
+					},
+				},
+				openBrowser: func(authURL string) error {
+					if scenario.inRedirect == nil {
+						return nil
+					}
+					go authServer.approve(t, authURL, scenario.inRedirect)
+					return nil
+				},
+				timeout: scenario.inTimeout,
+				out:     ioutil.Discard,
+			}
+
+			// call
+			result, resultErr := flow.getToken(context.Background())
+
+			// validate
+			if scenario.expectedErr != "" {
+				assert.EqualError(t, resultErr, scenario.expectedErr, scenario.desc)
+				return
+			}
+
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedToken, result.AccessToken, scenario.desc)
+			assert.Equal(t, "refresh-token", result.RefreshToken, scenario.desc)
+		})
+	}
+}
+
+// testAuthServer is a fake authorization server; the token endpoint only accepts the PKCE verifier that matches the
+// challenge sent in the authorization URL and rejects the refresh token "revoked"
+type testAuthServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	challenge string
+}
+
+func newTestAuthServer(t *testing.T) *testAuthServer {
+	server := &testAuthServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_ = req.ParseForm()
+
+		server.mutex.Lock()
+		challenge := server.challenge
+		server.mutex.Unlock()
+
+		valid := req.PostForm.Get("code") == "auth-code" && codeChallenge(req.PostForm.Get("code_verifier")) == challenge
+		if req.PostForm.Get("grant_type") == "refresh_token" {
+			valid = req.PostForm.Get("refresh_token") != "revoked"
+		}
+
+		if !valid {
+			resp.Header().Set("Content-Type", "application/json")
+			resp.WriteHeader(http.StatusBadRequest)
+			_, _ = resp.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+
+		resp.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(resp).Encode(map[string]interface{}{
+			"access_token":  "access-token",
+			"refresh_token": "refresh-token",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	}))
+
+	return server
+}
+
+// acts as the user approving access in the browser; the browser is redirected to the local listener
+func (s *testAuthServer) approve(t *testing.T, authURL string, modify func(query url.Values)) {
+	parsed, err := url.Parse(authURL)
+	assert.Nil(t, err)
+
+	params := parsed.Query()
+	assert.Equal(t, "S256", params.Get("code_challenge_method"))
+
+	s.mutex.Lock()
+	s.challenge = params.Get("code_challenge")
+	s.mutex.Unlock()
+
+	query := url.Values{}
+	query.Set("code", "auth-code")
+	query.Set("state", params.Get("state"))
+	modify(query)
+
+	// the listener may already be closed when the response arrives
+	resp, err := http.Get(params.Get("redirect_uri") + "?" + query.Encode())
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+}
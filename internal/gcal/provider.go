@@ -0,0 +1,128 @@
+package gcal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+const (
+	// ProviderGoogle is the name of the Google Calendar provider
+	ProviderGoogle = "google"
+)
+
+// Provider is a source of calendars.  The users are mapped from PD user id to email and the result is mapped by
+// PD user id
+type Provider interface {
+	GetCalendars(users map[string]string, start time.Time, end time.Time) (map[string]*Calendar, error)
+}
+
+// GoogleProvider loads the calendars from Google Calendar
+type GoogleProvider struct {
+	API             *CalendarAPI
+	CredentialsFile string
+	TokenFile       string
+}
+
+// GetCalendars implements Provider
+func (g *GoogleProvider) GetCalendars(users map[string]string, start time.Time, end time.Time) (map[string]*Calendar, error) {
+	return g.API.GetCalendars(g.CredentialsFile, g.TokenFile, users, start, end)
+}
+
+// UserSource is the calendar configuration of a single user
+type UserSource struct {
+	// Provider is the name of the provider (e.g. "google" or "ics")
+	Provider string `json:"provider"`
+
+	// Source is provider specific (e.g. the path or URL of the ICS file)
+	Source string `json:"source,omitempty"`
+}
+
+// LoadUserSources reads the per-user calendar configuration (mapped by PD user id or email) from a JSON file
+// in the format {"users": {"alice@example.com": {"provider": "ics", "source": "https://example.com/alice.ics"}}}
+func LoadUserSources(filename string) (map[string]*UserSource, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &struct {
+		Users map[string]*UserSource `json:"users"`
+	}{}
+	err = json.Unmarshal(b, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse calendar sources file '%s': %s", filename, err)
+	}
+
+	return config.Users, nil
+}
+
+// Router loads each user's calendar from the provider assigned to them
+type Router struct {
+	// Default serves the users without an assignment
+	Default Provider
+
+	// Providers mapped by name
+	Providers map[string]Provider
+
+	// Sources assigns users (by PD user id or email) to a provider
+	Sources map[string]*UserSource
+}
+
+// GetCalendars implements Provider
+func (r *Router) GetCalendars(users map[string]string, start time.Time, end time.Time) (map[string]*Calendar, error) {
+	// split the users by provider
+	byProvider := map[string]map[string]string{}
+	for id, email := range users {
+		name := ""
+		if source := r.source(id, email); source != nil {
+			name = source.Provider
+		}
+
+		if byProvider[name] == nil {
+			byProvider[name] = map[string]string{}
+		}
+		byProvider[name][id] = email
+	}
+
+	// load in a predictable order
+	var names []string
+	for name := range byProvider {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := map[string]*Calendar{}
+	for _, name := range names {
+		provider := r.Default
+		if name != "" {
+			provider = r.Providers[name]
+		}
+
+		if provider == nil {
+			return nil, fmt.Errorf("unknown calendar provider '%s'", name)
+		}
+
+		calendars, err := provider.GetCalendars(byProvider[name], start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, calendar := range calendars {
+			out[id] = calendar
+		}
+	}
+
+	return out, nil
+}
+
+// returns the source for the user (by PD user id first) or nil
+func (r *Router) source(id string, email string) *UserSource {
+	if source, found := r.Sources[id]; found {
+		return source
+	}
+
+	return r.Sources[email]
+}
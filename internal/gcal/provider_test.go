@@ -0,0 +1,95 @@
+package gcal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_GetCalendars(t *testing.T) {
+	// inputs
+	google := &stubProvider{}
+	ics := &stubProvider{}
+
+	router := &Router{
+		Default: google,
+		Providers: map[string]Provider{
+			"google": google,
+			"ics":    ics,
+		},
+		Sources: map[string]*UserSource{
+			"BAR":             {Provider: "ics", Source: "bar.ics"},
+			"baz@example.com": {Provider: "ics", Source: "baz.ics"},
+		},
+	}
+	users := map[string]string{
+		"FOO": "foo@example.com",
+		"BAR": "bar@example.com",
+		"BAZ": "baz@example.com",
+	}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// call
+	result, resultErr := router.GetCalendars(users, start, start.AddDate(0, 1, 0))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, map[string]string{"FOO": "foo@example.com"}, google.users)
+	assert.Equal(t, map[string]string{"BAR": "bar@example.com", "BAZ": "baz@example.com"}, ics.users)
+}
+
+func TestRouter_GetCalendars_unknownProvider(t *testing.T) {
+	// inputs
+	router := &Router{
+		Default: &stubProvider{},
+		Sources: map[string]*UserSource{
+			"FOO": {Provider: "outlook"},
+		},
+	}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// call
+	_, resultErr := router.GetCalendars(map[string]string{"FOO": "foo@example.com"}, start, start.AddDate(0, 1, 0))
+
+	// validate
+	assert.EqualError(t, resultErr, "unknown calendar provider 'outlook'")
+}
+
+func TestLoadUserSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sources")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "sources.json")
+	content := `{"users": {"alice@example.com": {"provider": "ics", "source": "https://example.com/alice.ics"}}}`
+	err = ioutil.WriteFile(filename, []byte(content), 0600)
+	assert.Nil(t, err)
+
+	// call
+	result, resultErr := LoadUserSources(filename)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, &UserSource{Provider: "ics", Source: "https://example.com/alice.ics"}, result["alice@example.com"])
+}
+
+// stubProvider returns an empty calendar for each user and records the users it was asked for
+type stubProvider struct {
+	users map[string]string
+}
+
+func (s *stubProvider) GetCalendars(users map[string]string, start time.Time, end time.Time) (map[string]*Calendar, error) {
+	s.users = users
+
+	out := map[string]*Calendar{}
+	for id := range users {
+		out[id] = &Calendar{}
+	}
+
+	return out, nil
+}
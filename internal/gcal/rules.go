@@ -0,0 +1,255 @@
+package gcal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// EventTypeOutOfOffice is the Google Calendar event type created by the "Out of office" option in the UI
+	EventTypeOutOfOffice = "outOfOffice"
+)
+
+// Rule describes which calendar events mean that a user is unavailable.
+//
+// An event matches when any of Keywords, TitleRegex, DescriptionRegex or EventTypes matches (a rule without any of
+// these matches every event) and the event also passes all of the Transparency, Visibility and MinDuration filters
+type Rule struct {
+	Name string `json:"name"`
+
+	// Keywords are matched (case-insensitive) as whole words in the title
+	Keywords []string `json:"keywords,omitempty"`
+
+	// TitleRegex and DescriptionRegex are Go regular expressions
+	TitleRegex       string `json:"title_regex,omitempty"`
+	DescriptionRegex string `json:"description_regex,omitempty"`
+
+	// EventTypes are native event types (e.g. "outOfOffice")
+	EventTypes []string `json:"event_types,omitempty"`
+
+	// Transparency is either "opaque" (busy) or "transparent" (free); empty matches both
+	Transparency string `json:"transparency,omitempty"`
+
+	// Visibility is the list of allowed visibilities (e.g. "default", "public"); empty matches all
+	Visibility []string `json:"visibility,omitempty"`
+
+	// MinDuration is the minimum length of the event (e.g. "4h"); empty matches all
+	MinDuration string `json:"min_duration,omitempty"`
+
+	keywords         []*regexp.Regexp
+	titleRegex       *regexp.Regexp
+	descriptionRegex *regexp.Regexp
+	minDuration      time.Duration
+}
+
+// Event is a calendar event in a form that is independent of the calendar provider
+type Event struct {
+	ID           string
+	Summary      string
+	Description  string
+	EventType    string
+	Transparency string
+	Visibility   string
+	HTMLLink     string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+}
+
+// DefaultRules returns the rules used when none are configured; these match the "out" and "xoncall" searches used
+// by earlier versions and the native out of office event
+func DefaultRules() []*Rule {
+	return []*Rule{
+		{
+			Name:       "out of office",
+			Keywords:   []string{"out"},
+			EventTypes: []string{EventTypeOutOfOffice},
+		},
+		{
+			Name:     "no on-call",
+			Keywords: []string{"xoncall"},
+		},
+	}
+}
+
+// LoadRules reads a JSON list of rules from the supplied file
+func LoadRules(filename string) ([]*Rule, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Rule
+	err = json.Unmarshal(b, &out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rules file '%s': %s", filename, err)
+	}
+
+	return out, nil
+}
+
+// Compile validates the rule and prepares it for matching
+func (r *Rule) Compile() error {
+	r.keywords = nil
+	for _, keyword := range r.Keywords {
+		if strings.TrimSpace(keyword) == "" {
+			continue
+		}
+		r.keywords = append(r.keywords, keywordRegex(keyword))
+	}
+
+	var err error
+	r.titleRegex, err = compileOptional(r.TitleRegex)
+	if err != nil {
+		return fmt.Errorf("rule '%s' has an invalid title regex: %s", r.Name, err)
+	}
+
+	r.descriptionRegex, err = compileOptional(r.DescriptionRegex)
+	if err != nil {
+		return fmt.Errorf("rule '%s' has an invalid description regex: %s", r.Name, err)
+	}
+
+	r.minDuration = 0
+	if r.MinDuration != "" {
+		r.minDuration, err = time.ParseDuration(r.MinDuration)
+		if err != nil {
+			return fmt.Errorf("rule '%s' has an invalid minimum duration: %s", r.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true when the event matches this rule.  Compile must be called first
+func (r *Rule) Matches(event *Event) bool {
+	if !r.passesFilters(event) {
+		return false
+	}
+
+	if len(r.keywords) == 0 && r.titleRegex == nil && r.descriptionRegex == nil && len(r.EventTypes) == 0 {
+		return true
+	}
+
+	for _, keyword := range r.keywords {
+		if keyword.MatchString(event.Summary) {
+			return true
+		}
+	}
+
+	if r.titleRegex != nil && r.titleRegex.MatchString(event.Summary) {
+		return true
+	}
+
+	if r.descriptionRegex != nil && r.descriptionRegex.MatchString(event.Description) {
+		return true
+	}
+
+	return event.EventType != "" && containsFold(r.EventTypes, event.EventType)
+}
+
+func (r *Rule) passesFilters(event *Event) bool {
+	if r.Transparency != "" && !strings.EqualFold(r.Transparency, transparency(event)) {
+		return false
+	}
+
+	if len(r.Visibility) > 0 && !containsFold(r.Visibility, visibility(event)) {
+		return false
+	}
+
+	return event.End.Sub(event.Start) >= r.minDuration
+}
+
+// MatchRule returns the first of the (compiled) rules that matches the event or nil
+func MatchRule(rules []*Rule, event *Event) *Rule {
+	for _, rule := range rules {
+		if rule.Matches(event) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// NewCalendarItem returns the item recorded for an event that matched the rule
+func NewCalendarItem(event *Event, rule *Rule) *CalendarItem {
+	return &CalendarItem{
+		Start:    event.Start,
+		End:      event.End,
+		Rule:     rule.Name,
+		Summary:  event.Summary,
+		ID:       event.ID,
+		HTMLLink: event.HTMLLink,
+	}
+}
+
+// CompileRules compiles all of the supplied rules
+func CompileRules(rules []*Rule) error {
+	for _, rule := range rules {
+		err := rule.Compile()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// builds a case-insensitive regex that matches the keyword as a whole word.
+// Word boundaries are only required next to letters from alphabets that separate words with spaces, so that
+// keywords like "不在" still match inside "不在です"
+func keywordRegex(keyword string) *regexp.Regexp {
+	keyword = strings.TrimSpace(keyword)
+	runes := []rune(keyword)
+
+	pattern := regexp.QuoteMeta(keyword)
+	if needsBoundary(runes[0]) {
+		pattern = `(?:^|[^\p{L}\p{N}])` + pattern
+	}
+	if needsBoundary(runes[len(runes)-1]) {
+		pattern = pattern + `(?:$|[^\p{L}\p{N}])`
+	}
+
+	return regexp.MustCompile(`(?i)` + pattern)
+}
+
+func needsBoundary(r rune) bool {
+	return unicode.IsDigit(r) || unicode.In(r, unicode.Latin, unicode.Greek, unicode.Cyrillic)
+}
+
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile(expr)
+}
+
+// Google omits the default values
+func transparency(event *Event) string {
+	if event.Transparency == "" {
+		return "opaque"
+	}
+	return event.Transparency
+}
+
+func visibility(event *Event) string {
+	if event.Visibility == "" {
+		return "default"
+	}
+	return event.Visibility
+}
+
+func containsFold(values []string, value string) bool {
+	for _, thisValue := range values {
+		if strings.EqualFold(thisValue, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,199 @@
+package gcal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchRule(t *testing.T) {
+	start := time.Date(2019, 1, 2, 9, 0, 0, 0, time.UTC)
+	newEvent := func(summary string, hours int) *Event {
+		return &Event{Summary: summary, Start: start, End: start.Add(time.Duration(hours) * time.Hour)}
+	}
+
+	scenarios := []struct {
+		desc         string
+		inRules      []*Rule
+		inEvent      *Event
+		expectedRule string
+	}{
+		{
+			desc:         "default rules - out",
+			inRules:      DefaultRules(),
+			inEvent:      newEvent("Out - dentist", 2),
+			expectedRule: "out of office",
+		},
+		{
+			desc:         "default rules - xoncall",
+			inRules:      DefaultRules(),
+			inEvent:      newEvent("xoncall: moving house", 8),
+			expectedRule: "no on-call",
+		},
+		{
+			desc:         "default rules - keyword inside another word",
+			inRules:      DefaultRules(),
+			inEvent:      newEvent("Checkout design review", 1),
+			expectedRule: "",
+		},
+		{
+			desc:         "default rules - workout",
+			inRules:      DefaultRules(),
+			inEvent:      newEvent("Workout", 1),
+			expectedRule: "",
+		},
+		{
+			desc:    "default rules - native out of office event",
+			inRules: DefaultRules(),
+			inEvent: &Event{
+				Summary:   "Vacation",
+				EventType: EventTypeOutOfOffice,
+				Start:     start,
+				End:       start.Add(24 * time.Hour),
+			},
+			expectedRule: "out of office",
+		},
+		{
+			desc:         "german keyword",
+			inRules:      []*Rule{{Name: "abwesend", Keywords: []string{"abwesend"}}},
+			inEvent:      newEvent("Abwesend (Urlaub)", 8),
+			expectedRule: "abwesend",
+		},
+		{
+			desc:         "japanese keyword without spaces",
+			inRules:      []*Rule{{Name: "fuzai", Keywords: []string{"不在"}}},
+			inEvent:      newEvent("終日不在です", 8),
+			expectedRule: "fuzai",
+		},
+		{
+			desc:         "title regex",
+			inRules:      []*Rule{{Name: "leave", TitleRegex: `(?i)^(annual|sick) leave`}},
+			inEvent:      newEvent("Sick leave", 8),
+			expectedRule: "leave",
+		},
+		{
+			desc:    "description regex",
+			inRules: []*Rule{{Name: "no pages", DescriptionRegex: `#no-?pages`}},
+			inEvent: &Event{
+				Summary:     "Conference",
+				Description: "travelling #nopages",
+				Start:       start,
+				End:         start.Add(8 * time.Hour),
+			},
+			expectedRule: "no pages",
+		},
+		{
+			desc:         "minimum duration - too short",
+			inRules:      []*Rule{{Name: "long", Keywords: []string{"out"}, MinDuration: "4h"}},
+			inEvent:      newEvent("Out", 2),
+			expectedRule: "",
+		},
+		{
+			desc:         "minimum duration - long enough",
+			inRules:      []*Rule{{Name: "long", Keywords: []string{"out"}, MinDuration: "4h"}},
+			inEvent:      newEvent("Out", 4),
+			expectedRule: "long",
+		},
+		{
+			desc:    "transparency - free events are ignored",
+			inRules: []*Rule{{Name: "busy", Keywords: []string{"out"}, Transparency: "opaque"}},
+			inEvent: &Event{
+				Summary:      "Out",
+				Transparency: "transparent",
+				Start:        start,
+				End:          start.Add(time.Hour),
+			},
+			expectedRule: "",
+		},
+		{
+			desc:         "visibility - default visibility is allowed",
+			inRules:      []*Rule{{Name: "visible", Keywords: []string{"out"}, Visibility: []string{"default", "public"}}},
+			inEvent:      newEvent("Out", 1),
+			expectedRule: "visible",
+		},
+		{
+			desc:         "no criteria - every event that passes the filters",
+			inRules:      []*Rule{{Name: "all day", MinDuration: "24h"}},
+			inEvent:      newEvent("Team offsite", 24),
+			expectedRule: "all day",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			err := CompileRules(scenario.inRules)
+			assert.Nil(t, err, scenario.desc)
+
+			// call
+			result := MatchRule(scenario.inRules, scenario.inEvent)
+
+			// validate
+			if scenario.expectedRule == "" {
+				assert.Nil(t, result, scenario.desc)
+				return
+			}
+
+			assert.NotNil(t, result, scenario.desc)
+			if result != nil {
+				assert.Equal(t, scenario.expectedRule, result.Name, scenario.desc)
+			}
+		})
+	}
+}
+
+func TestRule_Compile_invalid(t *testing.T) {
+	scenarios := []struct {
+		desc   string
+		inRule *Rule
+	}{
+		{
+			desc:   "invalid title regex",
+			inRule: &Rule{Name: "bad", TitleRegex: "("},
+		},
+		{
+			desc:   "invalid description regex",
+			inRule: &Rule{Name: "bad", DescriptionRegex: "[a-"},
+		},
+		{
+			desc:   "invalid duration",
+			inRule: &Rule{Name: "bad", MinDuration: "4 hours"},
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			resultErr := scenario.inRule.Compile()
+
+			// validate
+			assert.NotNil(t, resultErr, scenario.desc)
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "rules.json")
+	content := `[{"name": "ooo", "keywords": ["out", "abwesend"], "event_types": ["outOfOffice"], "min_duration": "1h"}]`
+	err = ioutil.WriteFile(filename, []byte(content), 0600)
+	assert.Nil(t, err)
+
+	// call
+	result, resultErr := LoadRules(filename)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, []string{"out", "abwesend"}, result[0].Keywords)
+	assert.Equal(t, "1h", result[0].MinDuration)
+	assert.Nil(t, CompileRules(result))
+}
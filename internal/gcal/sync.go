@@ -0,0 +1,344 @@
+package gcal
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+	"google.golang.org/api/calendar/v3"
+)
+
+const (
+	// private extended properties used to find the events published by a previous sync
+	propertySchedule = "pagerdutySchedule"
+	propertyUser     = "pagerdutyUser"
+
+	// event IDs may only use the characters a-v and 0-9
+	shiftEventIDPrefix = "pdoncall"
+
+	// SyncMargin is how far before and after the period the schedule should be loaded for Sync.
+	// PD cuts the shifts off at the requested period; loading more keeps the shifts that cross the period whole so
+	// that their events (and IDs) do not change between runs
+	SyncMargin = 31 * 24 * time.Hour
+)
+
+// ShiftEvent is an on-call shift published as a calendar event
+type ShiftEvent struct {
+	ID          string
+	Summary     string
+	Description string
+	ScheduleID  string
+	UserID      string
+	Start       time.Time
+	End         time.Time
+
+	// Cancelled is true for events that were deleted (Google keeps the ID of deleted events)
+	Cancelled bool
+}
+
+// EventStore reads and writes the on-call events of a calendar
+type EventStore interface {
+	// List returns the events (including cancelled ones) published for the schedule that overlap start and end
+	List(calendarID string, scheduleID string, start time.Time, end time.Time) ([]*ShiftEvent, error)
+	Insert(calendarID string, event *ShiftEvent) error
+	Update(calendarID string, event *ShiftEvent) error
+	Delete(calendarID string, eventID string) error
+}
+
+// SyncResult is an output DTO; it counts the changes made to the calendars
+type SyncResult struct {
+	Created   int
+	Updated   int
+	Deleted   int
+	Unchanged int
+
+	// Skipped are the names of the users whose shifts were not published (no email)
+	Skipped []string
+}
+
+// Sync publishes the shifts of the schedule between start and end as "On call: <schedule>" events.
+// The schedule should be loaded with SyncMargin before start and after end.
+// When calendarID is empty the shifts are published to each user's own calendar (users maps PD user ids to emails),
+// otherwise all shifts are published to that (shared) calendar.
+// Re-running only touches the shifts that changed. With per user calendars only the calendars of the supplied users
+// are checked; the events of a user that is no longer in the loaded schedule (including SyncMargin) are not removed.
+func (c *CalendarAPI) Sync(credentialsFile, tokenFile string, schedule *pduty.Schedule, users map[string]string, calendarID string, start time.Time, end time.Time) (*SyncResult, error) {
+	if calendarID != "" && c.AuthMode == AuthModeServiceAccount && c.Subject == "" {
+		return nil, fmt.Errorf("publishing to a shared calendar with a service account requires a subject with access to it")
+	}
+
+	apis, err := c.getAPIs(credentialsFile, tokenFile, calendar.CalendarEventsScope)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := syncSchedule(&googleEventStore{apis: apis}, schedule, users, calendarID, start, end)
+	if isInvalidGrant(err) {
+		return nil, ErrInvalidGrant
+	}
+
+	return out, err
+}
+
+func syncSchedule(store EventStore, schedule *pduty.Schedule, users map[string]string, calendarID string, start time.Time, end time.Time) (*SyncResult, error) {
+	out := &SyncResult{}
+
+	// the events each calendar should have (calendar ID -> event ID -> event)
+	wanted := map[string]map[string]*ShiftEvent{}
+	if calendarID != "" {
+		wanted[calendarID] = map[string]*ShiftEvent{}
+	} else {
+		// include every user (including those whose only shifts are in the margin around the period) so that their
+		// shifts that moved to someone else are removed
+		for _, email := range users {
+			if email != "" {
+				wanted[email] = map[string]*ShiftEvent{}
+			}
+		}
+	}
+
+	skipped := map[string]bool{}
+	for _, entry := range schedule.Entries {
+		if !entry.Start.Before(end) || !entry.End.After(start) {
+			continue
+		}
+
+		target := calendarID
+		if target == "" {
+			target = users[entry.User.ID]
+			if target == "" {
+				if !skipped[entry.User.ID] {
+					skipped[entry.User.ID] = true
+					out.Skipped = append(out.Skipped, entry.User.Name)
+				}
+				continue
+			}
+		}
+
+		event := newShiftEvent(schedule, entry, calendarID != "")
+		wanted[target][event.ID] = event
+	}
+
+	calendarIDs := make([]string, 0, len(wanted))
+	for id := range wanted {
+		calendarIDs = append(calendarIDs, id)
+	}
+	sort.Strings(calendarIDs)
+
+	for _, id := range calendarIDs {
+		err := syncCalendar(store, out, id, schedule.ID, wanted[id], start, end)
+		if err != nil {
+			// wrapped so that an expired sign in can be detected
+			return nil, fmt.Errorf("failed to sync calendar '%s': %w", id, err)
+		}
+	}
+
+	return out, nil
+}
+
+func syncCalendar(store EventStore, out *SyncResult, calendarID string, scheduleID string, wanted map[string]*ShiftEvent, start time.Time, end time.Time) error {
+	existing, err := store.List(calendarID, scheduleID, start, end)
+	if err != nil {
+		return err
+	}
+
+	found := map[string]*ShiftEvent{}
+	for _, event := range existing {
+		found[event.ID] = event
+
+		if event.Cancelled || wanted[event.ID] != nil {
+			continue
+		}
+
+		err = store.Delete(calendarID, event.ID)
+		if err != nil {
+			return err
+		}
+		out.Deleted++
+	}
+
+	ids := make([]string, 0, len(wanted))
+	for id := range wanted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		event := wanted[id]
+
+		current := found[id]
+		switch {
+		case current == nil:
+			err = store.Insert(calendarID, event)
+			out.Created++
+
+		case current.Cancelled:
+			// the ID cannot be reused by an insert; updating restores the event
+			err = store.Update(calendarID, event)
+			out.Created++
+
+		case current.Summary != event.Summary || current.Description != event.Description || current.UserID != event.UserID ||
+			!current.Start.Equal(event.Start) || !current.End.Equal(event.End):
+			err = store.Update(calendarID, event)
+			out.Updated++
+
+		default:
+			out.Unchanged++
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// the ID is derived from the schedule and the shift times so that re-runs find the same event
+func newShiftEvent(schedule *pduty.Schedule, entry *pduty.ScheduleEntry, shared bool) *ShiftEvent {
+	hash := sha1.Sum([]byte(schedule.ID + "|" + entry.Start.UTC().Format(time.RFC3339) + "|" + entry.End.UTC().Format(time.RFC3339)))
+
+	summary := "On call: " + schedule.Name
+	if shared {
+		summary += " - " + entry.User.Name
+	}
+
+	return &ShiftEvent{
+		ID:          shiftEventIDPrefix + hex.EncodeToString(hash[:]),
+		Summary:     summary,
+		Description: fmt.Sprintf("PagerDuty schedule %s (%s)", schedule.Name, schedule.ID),
+		ScheduleID:  schedule.ID,
+		UserID:      entry.User.ID,
+		Start:       entry.Start,
+		End:         entry.End,
+	}
+}
+
+// googleEventStore implements EventStore using the Google Calendar API
+type googleEventStore struct {
+	apis apiFactory
+}
+
+func (g *googleEventStore) List(calendarID string, scheduleID string, start time.Time, end time.Time) ([]*ShiftEvent, error) {
+	_, api, err := g.apis(calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*ShiftEvent
+
+	pageToken := ""
+	for page := 0; page < maxEventPages; page++ {
+		call := api.Events.List(calendarID).
+			PrivateExtendedProperty(propertySchedule + "=" + scheduleID).
+			ShowDeleted(true).
+			SingleEvents(true).
+			TimeMin(start.Format(time.RFC3339)).
+			TimeMax(end.Format(time.RFC3339))
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		result, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range result.Items {
+			event, err := fromGoogleEvent(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, event)
+		}
+
+		pageToken = result.NextPageToken
+		if pageToken == "" {
+			return out, nil
+		}
+	}
+
+	return nil, fmt.Errorf("too many on-call events")
+}
+
+func (g *googleEventStore) Insert(calendarID string, event *ShiftEvent) error {
+	_, api, err := g.apis(calendarID)
+	if err != nil {
+		return err
+	}
+
+	_, err = api.Events.Insert(calendarID, toGoogleEvent(event)).Do()
+	return err
+}
+
+func (g *googleEventStore) Update(calendarID string, event *ShiftEvent) error {
+	_, api, err := g.apis(calendarID)
+	if err != nil {
+		return err
+	}
+
+	_, err = api.Events.Update(calendarID, event.ID, toGoogleEvent(event)).Do()
+	return err
+}
+
+func (g *googleEventStore) Delete(calendarID string, eventID string) error {
+	_, api, err := g.apis(calendarID)
+	if err != nil {
+		return err
+	}
+
+	return api.Events.Delete(calendarID, eventID).Do()
+}
+
+func toGoogleEvent(event *ShiftEvent) *calendar.Event {
+	return &calendar.Event{
+		Id:          event.ID,
+		Summary:     event.Summary,
+		Description: event.Description,
+		Status:      "confirmed",
+		// being on-call should not block the calendar
+		Transparency: "transparent",
+		Start:        &calendar.EventDateTime{DateTime: event.Start.Format(time.RFC3339)},
+		End:          &calendar.EventDateTime{DateTime: event.End.Format(time.RFC3339)},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{
+				propertySchedule: event.ScheduleID,
+				propertyUser:     event.UserID,
+			},
+		},
+	}
+}
+
+func fromGoogleEvent(item *calendar.Event) (*ShiftEvent, error) {
+	out := &ShiftEvent{
+		ID:          item.Id,
+		Summary:     item.Summary,
+		Description: item.Description,
+		Cancelled:   item.Status == "cancelled",
+	}
+
+	if item.ExtendedProperties != nil {
+		out.ScheduleID = item.ExtendedProperties.Private[propertySchedule]
+		out.UserID = item.ExtendedProperties.Private[propertyUser]
+	}
+
+	// cancelled events may not include their times
+	if out.Cancelled || item.Start == nil || item.End == nil {
+		return out, nil
+	}
+
+	var err error
+	out.Start, err = time.Parse(time.RFC3339, item.Start.DateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	out.End, err = time.Parse(time.RFC3339, item.End.DateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
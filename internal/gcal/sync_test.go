@@ -0,0 +1,288 @@
+package gcal
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty"
+	"github.com/corsc/pagerduty-gcal/internal/pduty/pdtest"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestSyncSchedule(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+
+	foo := &pduty.User{ID: "FOO", Name: "Foo"}
+	bar := &pduty.User{ID: "BAR", Name: "Bar"}
+	baz := &pduty.User{ID: "BAZ", Name: "Baz"}
+
+	day1 := &pduty.ScheduleEntry{User: foo, Start: start, End: start.AddDate(0, 0, 1)}
+	day2 := &pduty.ScheduleEntry{User: bar, Start: start.AddDate(0, 0, 1), End: start.AddDate(0, 0, 2)}
+	day3 := &pduty.ScheduleEntry{User: baz, Start: start.AddDate(0, 0, 2), End: start.AddDate(0, 0, 3)}
+
+	schedule := &pduty.Schedule{ID: "SCHED", Name: "Primary", Entries: []*pduty.ScheduleEntry{day1, day2, day3}}
+	users := map[string]string{"FOO": "foo@example.com", "BAR": "bar@example.com"}
+
+	scenarios := []struct {
+		desc             string
+		inCalendarID     string
+		inExisting       map[string][]*ShiftEvent
+		expectedResult   *SyncResult
+		expectedCalendar map[string][]string
+	}{
+		{
+			desc:         "first run - own calendars",
+			inCalendarID: "",
+			inExisting:   map[string][]*ShiftEvent{},
+			expectedResult: &SyncResult{
+				Created: 2,
+				Skipped: []string{"Baz"},
+			},
+			expectedCalendar: map[string][]string{
+				"foo@example.com": {"On call: Primary"},
+				"bar@example.com": {"On call: Primary"},
+			},
+		},
+		{
+			desc:         "re-run - nothing changed",
+			inCalendarID: "",
+			inExisting: map[string][]*ShiftEvent{
+				"foo@example.com": {newShiftEvent(schedule, day1, false)},
+				"bar@example.com": {newShiftEvent(schedule, day2, false)},
+			},
+			expectedResult: &SyncResult{
+				Unchanged: 2,
+				Skipped:   []string{"Baz"},
+			},
+			expectedCalendar: map[string][]string{
+				"foo@example.com": {"On call: Primary"},
+				"bar@example.com": {"On call: Primary"},
+			},
+		},
+		{
+			desc:         "shift moved to another user",
+			inCalendarID: "",
+			inExisting: map[string][]*ShiftEvent{
+				"foo@example.com": {newShiftEvent(schedule, day1, false), newShiftEvent(schedule, &pduty.ScheduleEntry{User: foo, Start: day2.Start, End: day2.End}, false)},
+			},
+			expectedResult: &SyncResult{
+				Created:   1,
+				Deleted:   1,
+				Unchanged: 1,
+				Skipped:   []string{"Baz"},
+			},
+			expectedCalendar: map[string][]string{
+				"foo@example.com": {"On call: Primary"},
+				"bar@example.com": {"On call: Primary"},
+			},
+		},
+		{
+			desc:         "shared calendar - changed user, removed shift and previously deleted event",
+			inCalendarID: "team@example.com",
+			inExisting: map[string][]*ShiftEvent{
+				"team@example.com": {
+					newShiftEvent(schedule, &pduty.ScheduleEntry{User: bar, Start: day1.Start, End: day1.End}, true),
+					withCancelled(newShiftEvent(schedule, day2, true)),
+					newShiftEvent(schedule, day3, true),
+					newShiftEvent(schedule, &pduty.ScheduleEntry{User: baz, Start: day3.End, End: day3.End.AddDate(0, 0, 1)}, true),
+				},
+			},
+			expectedResult: &SyncResult{
+				Created:   1,
+				Updated:   1,
+				Deleted:   1,
+				Unchanged: 1,
+			},
+			expectedCalendar: map[string][]string{
+				"team@example.com": {"On call: Primary - Foo", "On call: Primary - Bar", "On call: Primary - Baz"},
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			store := &fakeEventStore{events: map[string][]*ShiftEvent{}}
+			for calendarID, events := range scenario.inExisting {
+				store.events[calendarID] = events
+			}
+
+			// call
+			result, resultErr := syncSchedule(store, schedule, users, scenario.inCalendarID, start, end)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedResult, result, scenario.desc)
+			for calendarID, expected := range scenario.expectedCalendar {
+				assert.Equal(t, expected, store.summaries(calendarID), scenario.desc)
+			}
+		})
+	}
+}
+
+func TestSyncSchedule_userLeftRotation(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	foo := &pduty.User{ID: "FOO", Name: "Foo"}
+	bar := &pduty.User{ID: "BAR", Name: "Bar"}
+
+	// BAR only has a shift before the period (i.e. in the margin); their shift in the period was given to FOO
+	schedule := &pduty.Schedule{
+		ID:   "SCHED",
+		Name: "Primary",
+		Entries: []*pduty.ScheduleEntry{
+			{User: bar, Start: start.AddDate(0, 0, -7), End: start.AddDate(0, 0, -6)},
+			{User: foo, Start: start, End: start.AddDate(0, 0, 1)},
+		},
+	}
+	users := map[string]string{"FOO": "foo@example.com", "BAR": "bar@example.com"}
+
+	store := &fakeEventStore{events: map[string][]*ShiftEvent{
+		"bar@example.com": {newShiftEvent(schedule, &pduty.ScheduleEntry{User: bar, Start: start, End: start.AddDate(0, 0, 1)}, false)},
+	}}
+
+	// call
+	result, resultErr := syncSchedule(store, schedule, users, "", start, start.AddDate(0, 0, 7))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, &SyncResult{Created: 1, Deleted: 1}, result)
+	assert.Empty(t, store.summaries("bar@example.com"))
+	assert.Equal(t, []string{"On call: Primary"}, store.summaries("foo@example.com"))
+}
+
+func TestSyncSchedule_storeError(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := &pduty.Schedule{
+		ID: "SCHED",
+		Entries: []*pduty.ScheduleEntry{
+			{User: &pduty.User{ID: "FOO"}, Start: start, End: start.AddDate(0, 0, 1)},
+		},
+	}
+	store := &fakeEventStore{events: map[string][]*ShiftEvent{}, err: fmt.Errorf("forbidden")}
+
+	// call
+	_, resultErr := syncSchedule(store, schedule, map[string]string{"FOO": "foo@example.com"}, "", start, start.AddDate(0, 0, 7))
+
+	// validate
+	assert.EqualError(t, resultErr, "failed to sync calendar 'foo@example.com': forbidden")
+}
+
+func TestSyncSchedule_invalidGrant(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := &pduty.Schedule{
+		ID: "SCHED",
+		Entries: []*pduty.ScheduleEntry{
+			{User: &pduty.User{ID: "FOO"}, Start: start, End: start.AddDate(0, 0, 1)},
+		},
+	}
+	storeErr := &url.Error{Op: "Get", URL: "https://example.com", Err: &oauth2.RetrieveError{Body: []byte(`{"error": "invalid_grant"}`)}}
+	store := &fakeEventStore{events: map[string][]*ShiftEvent{}, err: storeErr}
+
+	// call
+	_, resultErr := syncSchedule(store, schedule, map[string]string{"FOO": "foo@example.com"}, "", start, start.AddDate(0, 0, 7))
+
+	// validate
+	assert.True(t, isInvalidGrant(resultErr))
+}
+
+func TestSyncSchedule_shiftedPeriod(t *testing.T) {
+	server := pdtest.NewServer("key")
+	defer server.Close()
+
+	// weekly shifts that start on Monday at 09:00
+	monday := time.Date(2019, 1, 7, 9, 0, 0, 0, time.UTC)
+	server.AddUser(&pdtest.User{ID: "FOO", Name: "Foo"})
+	server.AddUser(&pdtest.User{ID: "BAR", Name: "Bar"})
+	server.AddSchedule(&pdtest.Schedule{
+		ID:   "SCHED",
+		Name: "Primary",
+		Entries: []*pdtest.ScheduleEntry{
+			{UserID: "FOO", Start: monday, End: monday.AddDate(0, 0, 7)},
+			{UserID: "BAR", Start: monday.AddDate(0, 0, 7), End: monday.AddDate(0, 0, 14)},
+			{UserID: "FOO", Start: monday.AddDate(0, 0, 14), End: monday.AddDate(0, 0, 21)},
+		},
+	})
+
+	scheduleAPI := pduty.NewScheduleAPI(pduty.WithAPIKey("key"), pduty.WithBaseURL(server.URL))
+	users := map[string]string{"FOO": "foo@example.com", "BAR": "bar@example.com"}
+	store := &fakeEventStore{events: map[string][]*ShiftEvent{}}
+
+	// a daily run that publishes the next 7 days
+	sync := func(start time.Time) *SyncResult {
+		end := start.AddDate(0, 0, 7)
+
+		schedule, err := scheduleAPI.GetSchedule("SCHED", start.Add(-SyncMargin), end.Add(SyncMargin))
+		assert.Nil(t, err)
+
+		result, err := syncSchedule(store, schedule, users, "", start, end)
+		assert.Nil(t, err)
+		return result
+	}
+
+	// call
+	first := sync(time.Date(2019, 1, 10, 0, 0, 0, 0, time.UTC))
+	second := sync(time.Date(2019, 1, 11, 0, 0, 0, 0, time.UTC))
+
+	// validate
+	assert.Equal(t, &SyncResult{Created: 2}, first)
+	assert.Equal(t, &SyncResult{Unchanged: 2}, second)
+
+	// the shifts that cross the period are published in full
+	assert.Equal(t, 1, len(store.events["foo@example.com"]))
+	assert.True(t, store.events["foo@example.com"][0].Start.Equal(monday))
+	assert.Equal(t, 1, len(store.events["bar@example.com"]))
+	assert.True(t, store.events["bar@example.com"][0].End.Equal(monday.AddDate(0, 0, 14)))
+}
+
+func withCancelled(event *ShiftEvent) *ShiftEvent {
+	event.Cancelled = true
+	return event
+}
+
+// fakeEventStore keeps the events of each calendar in memory
+type fakeEventStore struct {
+	events map[string][]*ShiftEvent
+	err    error
+}
+
+func (f *fakeEventStore) List(calendarID string, scheduleID string, start time.Time, end time.Time) ([]*ShiftEvent, error) {
+	return f.events[calendarID], f.err
+}
+
+func (f *fakeEventStore) Insert(calendarID string, event *ShiftEvent) error {
+	f.events[calendarID] = append(f.events[calendarID], event)
+	return f.err
+}
+
+func (f *fakeEventStore) Update(calendarID string, event *ShiftEvent) error {
+	for index, existing := range f.events[calendarID] {
+		if existing.ID == event.ID {
+			f.events[calendarID][index] = event
+		}
+	}
+	return f.err
+}
+
+func (f *fakeEventStore) Delete(calendarID string, eventID string) error {
+	for _, existing := range f.events[calendarID] {
+		if existing.ID == eventID {
+			existing.Cancelled = true
+		}
+	}
+	return f.err
+}
+
+// returns the summaries of the events that are not cancelled
+func (f *fakeEventStore) summaries(calendarID string) []string {
+	var out []string
+	for _, event := range f.events[calendarID] {
+		if !event.Cancelled {
+			out = append(out, event.Summary)
+		}
+	}
+	return out
+}
@@ -0,0 +1,237 @@
+package gcal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// pbkdf2 parameters used to derive the key for the encrypted token file
+	keyIterations = 600000
+	keyLength     = 32
+	saltLength    = 16
+)
+
+var (
+	// ErrNoToken is returned by a TokenStore that does not contain a token yet
+	ErrNoToken = errors.New("no saved Google token")
+
+	// ErrInvalidGrant is returned when the saved token has expired or been revoked
+	ErrInvalidGrant = errors.New("the saved Google token has expired or been revoked; please sign in again")
+)
+
+// TokenStore loads and saves the OAuth token used to access Google Calendar
+type TokenStore interface {
+	// Load returns the saved token or ErrNoToken
+	Load() (*oauth2.Token, error)
+
+	// Save replaces the saved token
+	Save(token *oauth2.Token) error
+}
+
+// FileTokenStore keeps the token in a plain JSON file
+type FileTokenStore struct {
+	Path string
+}
+
+// Load implements TokenStore
+func (f *FileTokenStore) Load() (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeToken(b)
+}
+
+// Save implements TokenStore
+func (f *FileTokenStore) Save(token *oauth2.Token) error {
+	fmt.Printf("Saving credential file to: %s\n", f.Path)
+
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(f.Path, b, 0600)
+}
+
+// EncryptedFileTokenStore keeps the token in a file encrypted (AES-GCM) with a key derived from the passphrase
+type EncryptedFileTokenStore struct {
+	Path       string
+	Passphrase string
+}
+
+// the format of the encrypted file
+type encryptedToken struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// Load implements TokenStore
+func (e *EncryptedFileTokenStore) Load() (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(e.Path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := &encryptedToken{}
+	err = json.Unmarshal(b, encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted token file '%s': %s", e.Path, err)
+	}
+
+	gcm, err := e.cipher(encrypted.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("failed to read encrypted token file '%s': invalid nonce", e.Path)
+	}
+
+	plain, err := gcm.Open(nil, encrypted.Nonce, encrypted.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token file '%s'; is the passphrase correct?", e.Path)
+	}
+
+	return decodeToken(plain)
+}
+
+// Save implements TokenStore
+func (e *EncryptedFileTokenStore) Save(token *oauth2.Token) error {
+	fmt.Printf("Saving encrypted credential file to: %s\n", e.Path)
+
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	encrypted := &encryptedToken{
+		Salt: make([]byte, saltLength),
+	}
+	_, err = rand.Read(encrypted.Salt)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := e.cipher(encrypted.Salt)
+	if err != nil {
+		return err
+	}
+
+	encrypted.Nonce = make([]byte, gcm.NonceSize())
+	_, err = rand.Read(encrypted.Nonce)
+	if err != nil {
+		return err
+	}
+
+	encrypted.Data = gcm.Seal(nil, encrypted.Nonce, plain, nil)
+
+	b, err := json.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(e.Path, b, 0600)
+}
+
+func (e *EncryptedFileTokenStore) cipher(salt []byte) (cipher.AEAD, error) {
+	if e.Passphrase == "" {
+		return nil, errors.New("a passphrase is required for the encrypted token file")
+	}
+
+	key, err := pbkdf2.Key(sha256.New, e.Passphrase, salt, keyIterations, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EnvTokenStore reads the token (as JSON) from an environment variable, or from Stdin when the variable is "-".
+// It is read only; tokens from a new sign in are only used for the current run
+type EnvTokenStore struct {
+	Variable string
+
+	// Stdin defaults to os.Stdin
+	Stdin io.Reader
+}
+
+// Load implements TokenStore
+func (e *EnvTokenStore) Load() (*oauth2.Token, error) {
+	value := strings.TrimSpace(os.Getenv(e.Variable))
+	if value == "" {
+		return nil, ErrNoToken
+	}
+
+	if value != "-" {
+		return decodeToken([]byte(value))
+	}
+
+	stdin := e.Stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	b, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, ErrNoToken
+	}
+
+	return decodeToken(b)
+}
+
+// Save implements TokenStore
+func (e *EnvTokenStore) Save(token *oauth2.Token) error {
+	return fmt.Errorf("the token cannot be saved to the environment variable %s", e.Variable)
+}
+
+func decodeToken(b []byte) (*oauth2.Token, error) {
+	token := &oauth2.Token{}
+	err := json.Unmarshal(b, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the saved Google token: %s", err)
+	}
+
+	return token, nil
+}
+
+// returns true when the error is the authorization server rejecting the refresh token
+func isInvalidGrant(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+
+	return bytes.Contains(retrieveErr.Body, []byte("invalid_grant"))
+}
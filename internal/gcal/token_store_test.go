@@ -0,0 +1,239 @@
+package gcal
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	scenarios := []struct {
+		desc  string
+		store TokenStore
+	}{
+		{
+			desc:  "plain file",
+			store: &FileTokenStore{Path: filepath.Join(dir, "token.json")},
+		},
+		{
+			desc:  "encrypted file",
+			store: &EncryptedFileTokenStore{Path: filepath.Join(dir, "token.enc"), Passphrase: "correct horse"},
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// inputs
+			token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"}
+
+			// call
+			_, noTokenErr := scenario.store.Load()
+			saveErr := scenario.store.Save(token)
+			result, resultErr := scenario.store.Load()
+
+			// validate
+			assert.Equal(t, ErrNoToken, noTokenErr, scenario.desc)
+			assert.Nil(t, saveErr, scenario.desc)
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, "refresh", result.RefreshToken, scenario.desc)
+		})
+	}
+}
+
+func TestEncryptedFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "token.enc")
+	err = (&EncryptedFileTokenStore{Path: filename, Passphrase: "correct horse"}).Save(&oauth2.Token{RefreshToken: "refresh"})
+	assert.Nil(t, err)
+
+	// the token is not stored in plain text
+	content, err := ioutil.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(content), "refresh"))
+
+	// call
+	_, resultErr := (&EncryptedFileTokenStore{Path: filename, Passphrase: "battery staple"}).Load()
+
+	// validate
+	assert.NotNil(t, resultErr)
+}
+
+func TestEnvTokenStore(t *testing.T) {
+	scenarios := []struct {
+		desc                 string
+		inValue              string
+		inStdin              string
+		expectedRefreshToken string
+		expectedErr          error
+	}{
+		{
+			desc:        "not set",
+			inValue:     "",
+			expectedErr: ErrNoToken,
+		},
+		{
+			desc:                 "token in the variable",
+			inValue:              `{"refresh_token": "from-env"}`,
+			expectedRefreshToken: "from-env",
+		},
+		{
+			desc:                 "token from stdin",
+			inValue:              "-",
+			inStdin:              `{"refresh_token": "from-stdin"}`,
+			expectedRefreshToken: "from-stdin",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// inputs
+			t.Setenv("TEST_GCAL_TOKEN", scenario.inValue)
+			store := &EnvTokenStore{Variable: "TEST_GCAL_TOKEN", Stdin: strings.NewReader(scenario.inStdin)}
+
+			// call
+			result, resultErr := store.Load()
+
+			// validate
+			if scenario.expectedErr != nil {
+				assert.Equal(t, scenario.expectedErr, resultErr, scenario.desc)
+				return
+			}
+
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedRefreshToken, result.RefreshToken, scenario.desc)
+			assert.NotNil(t, store.Save(result), scenario.desc)
+		})
+	}
+}
+
+func TestCalendarAPI_getClient_revokedToken(t *testing.T) {
+	scenarios := []struct {
+		desc             string
+		inReauthenticate func() bool
+		expectedErr      error
+		expectedSaved    bool
+	}{
+		{
+			desc:        "no re-authentication",
+			expectedErr: ErrInvalidGrant,
+		},
+		{
+			desc:             "user declines re-authentication",
+			inReauthenticate: func() bool { return false },
+			expectedErr:      ErrInvalidGrant,
+		},
+		{
+			desc:             "user signs in again",
+			inReauthenticate: func() bool { return true },
+			expectedSaved:    true,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// inputs
+			authServer := newTestAuthServer(t)
+			defer authServer.Close()
+
+			config := &oauth2.Config{
+				ClientID: "client",
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  authServer.URL + "/auth",
+					TokenURL: authServer.URL + "/token",
+				},
+			}
+
+			tokens := &memoryTokenStore{
+				token: &oauth2.Token{
+					AccessToken:  "expired",
+					RefreshToken: "revoked",
+					Expiry:       time.Now().Add(-time.Hour),
+				},
+			}
+
+			calAPI := &CalendarAPI{
+				Reauthenticate: scenario.inReauthenticate,
+				openBrowser: func(authURL string) error {
+					go authServer.approve(t, authURL, func(query url.Values) {})
+					return nil
+				},
+			}
+
+			// call
+			result, resultErr := calAPI.getClient(tokens, config)
+
+			// validate
+			assert.Equal(t, scenario.expectedErr, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedErr == nil, result != nil, scenario.desc)
+			assert.Equal(t, scenario.expectedSaved, tokens.token.RefreshToken == "refresh-token", scenario.desc)
+		})
+	}
+}
+
+func TestIsInvalidGrant(t *testing.T) {
+	scenarios := []struct {
+		desc     string
+		inErr    error
+		expected bool
+	}{
+		{
+			desc:     "no error",
+			inErr:    nil,
+			expected: false,
+		},
+		{
+			desc:     "invalid grant",
+			inErr:    &url.Error{Op: "Get", URL: "https://example.com", Err: &oauth2.RetrieveError{Body: []byte(`{"error": "invalid_grant"}`)}},
+			expected: true,
+		},
+		{
+			desc:     "other token error",
+			inErr:    &oauth2.RetrieveError{Body: []byte(`{"error": "invalid_client"}`)},
+			expected: false,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			result := isInvalidGrant(scenario.inErr)
+
+			// validate
+			assert.Equal(t, scenario.expected, result, scenario.desc)
+		})
+	}
+}
+
+type memoryTokenStore struct {
+	token *oauth2.Token
+}
+
+func (m *memoryTokenStore) Load() (*oauth2.Token, error) {
+	if m.token == nil {
+		return nil, ErrNoToken
+	}
+	return m.token, nil
+}
+
+func (m *memoryTokenStore) Save(token *oauth2.Token) error {
+	m.token = token
+	return nil
+}
@@ -0,0 +1,262 @@
+package holiday
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/ics"
+)
+
+const (
+	// Google publishes its holiday calendars (e.g. "en.singapore#holiday@group.v.calendar.google.com") as public ICS feeds
+	googleHolidayMarker = "#holiday@group.v.calendar.google.com"
+	googleFeedURL       = "https://calendar.google.com/calendar/ical/%s/public/basic.ics"
+
+	dateFormat = "2006-01-02"
+)
+
+// Holiday is a public holiday of a region; it lasts from midnight to midnight in the region's time zone
+type Holiday struct {
+	Region string
+	Name   string
+	Start  time.Time
+	End    time.Time
+}
+
+// Region describes where the public holidays of a region come from; one of File, ICS or GoogleCalendar is required
+type Region struct {
+	// TimeZone is the IANA time zone the holidays are observed in (default: the time zone of the ICS feed or UTC)
+	TimeZone string `json:"time_zone"`
+
+	// File is a JSON file of holidays (see DataFile)
+	File string `json:"file"`
+
+	// ICS is the path or URL of an iCalendar holiday feed
+	ICS string `json:"ics"`
+
+	// GoogleCalendar is the ID of a Google holiday calendar (e.g. "en.german#holiday@group.v.calendar.google.com")
+	GoogleCalendar string `json:"google_calendar"`
+}
+
+// Config assigns users to holiday regions
+type Config struct {
+	Regions map[string]*Region `json:"regions"`
+
+	// Users maps PD user ids or emails to a region name or a Google holiday calendar ID (observed in the time zone of
+	// the user's calendar)
+	Users map[string]string `json:"users"`
+}
+
+// DataFile is the format of a local holiday data file
+type DataFile struct {
+	Holidays []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"holidays"`
+}
+
+// LoadConfig reads the holiday regions and user assignments from a JSON file
+func LoadConfig(file string) (*Config, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Config{}
+	err = json.Unmarshal(contents, out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse holidays file '%s': %s", file, err)
+	}
+
+	for user, region := range out.Users {
+		if out.Regions[region] == nil && !isGoogleCalendar(region) {
+			return nil, fmt.Errorf("unknown holiday region '%s' for '%s'", region, user)
+		}
+	}
+
+	return out, nil
+}
+
+// API loads the public holidays of each user's region
+type API struct {
+	Config *Config
+
+	// HTTPClient is used for feeds (default: a client with a 60 second timeout)
+	HTTPClient *http.Client
+
+	// TimeZones are the IANA time zones of the users (mapped by PD user id).
+	// Required for users assigned directly to a Google holiday calendar as its all-day events are in UTC
+	TimeZones map[string]string
+}
+
+// GetHolidays returns the holidays between start and end of each user's region (mapped by PD user id).
+// Users without a region are not included
+func (a *API) GetHolidays(users map[string]string, start time.Time, end time.Time) (map[string][]*Holiday, error) {
+	out := map[string][]*Holiday{}
+
+	// regions are shared by many users; only load each once
+	loaded := map[string][]*Holiday{}
+
+	for id, email := range users {
+		name := a.region(id, email)
+		if name == "" {
+			continue
+		}
+
+		key, region := name, a.Config.Regions[name]
+		if region == nil {
+			// assigned directly to a Google holiday calendar; observe the holidays in the user's time zone
+			timeZone := a.TimeZones[id]
+			if timeZone == "" {
+				return nil, fmt.Errorf("no time zone for '%s' to observe '%s' in; assign them to a region with a time_zone", email, name)
+			}
+
+			key, region = name+"|"+timeZone, &Region{TimeZone: timeZone, GoogleCalendar: name}
+		}
+
+		holidays, found := loaded[key]
+		if !found {
+			var err error
+			holidays, err = a.getHolidays(name, region, start, end)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load holidays for region '%s': %s", name, err)
+			}
+			loaded[key] = holidays
+		}
+
+		out[id] = holidays
+	}
+
+	return out, nil
+}
+
+// returns the region for the user (by PD user id first)
+func (a *API) region(id string, email string) string {
+	if region, found := a.Config.Users[id]; found {
+		return region
+	}
+
+	return a.Config.Users[email]
+}
+
+func (a *API) getHolidays(name string, region *Region, start time.Time, end time.Time) ([]*Holiday, error) {
+	var location *time.Location
+	if region.TimeZone != "" {
+		var err error
+		location, err = time.LoadLocation(region.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var holidays []*Holiday
+	var err error
+
+	switch {
+	case region.File != "":
+		if location == nil {
+			location = time.UTC
+		}
+		holidays, err = loadFile(region.File, location)
+
+	case region.ICS != "":
+		holidays, err = a.loadICS(region.ICS, location, start, end)
+
+	case region.GoogleCalendar != "":
+		holidays, err = a.loadICS(fmt.Sprintf(googleFeedURL, url.QueryEscape(region.GoogleCalendar)), location, start, end)
+
+	default:
+		err = fmt.Errorf("no holiday source configured")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Holiday
+	for _, holiday := range holidays {
+		if holiday.Start.Before(end) && holiday.End.After(start) {
+			holiday.Region = name
+			out = append(out, holiday)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Start.Before(out[j].Start)
+	})
+
+	return out, nil
+}
+
+func loadFile(file string, location *time.Location) ([]*Holiday, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &DataFile{}
+	err = json.Unmarshal(contents, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse holiday data file '%s': %s", file, err)
+	}
+
+	var out []*Holiday
+	for _, item := range data.Holidays {
+		date, err := time.ParseInLocation(dateFormat, item.Date, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date '%s' in holiday data file '%s'", item.Date, file)
+		}
+
+		out = append(out, &Holiday{Name: item.Name, Start: date, End: date.AddDate(0, 0, 1)})
+	}
+
+	return out, nil
+}
+
+func (a *API) loadICS(source string, location *time.Location, start time.Time, end time.Time) ([]*Holiday, error) {
+	reader, err := ics.Open(source, a.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	parsed, err := ics.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// the all-day holidays are moved to the region's time zone below, which can shift them by up to a day
+	occurrences, err := parsed.Expand(start.AddDate(0, 0, -1), end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Holiday
+	for _, occurrence := range occurrences {
+		holiday := &Holiday{Name: occurrence.Event.Summary, Start: occurrence.Start, End: occurrence.End}
+
+		// holidays are dates; observe them in the region's time zone
+		if occurrence.Event.AllDay && location != nil {
+			holiday.Start = inLocation(occurrence.Start, location)
+			holiday.End = inLocation(occurrence.End, location)
+		}
+
+		out = append(out, holiday)
+	}
+
+	return out, nil
+}
+
+// returns midnight of the same date in the location
+func inLocation(date time.Time, location *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, location)
+}
+
+func isGoogleCalendar(value string) bool {
+	return strings.HasSuffix(value, googleHolidayMarker)
+}
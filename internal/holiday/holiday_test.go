@@ -0,0 +1,214 @@
+package holiday
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFeed = "BEGIN:VCALENDAR\n" +
+	"BEGIN:VEVENT\n" +
+	"UID:1\n" +
+	"SUMMARY:Tag der Deutschen Einheit\n" +
+	"DTSTART;VALUE=DATE:20191003\n" +
+	"DTEND;VALUE=DATE:20191004\n" +
+	"END:VEVENT\n" +
+	"BEGIN:VEVENT\n" +
+	"UID:2\n" +
+	"SUMMARY:Neujahr\n" +
+	"DTSTART;VALUE=DATE:20200101\n" +
+	"DTEND;VALUE=DATE:20200102\n" +
+	"END:VEVENT\n" +
+	"END:VCALENDAR\n"
+
+func TestAPI_GetHolidays(t *testing.T) {
+	// inputs
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = resp.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "holidays")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "sg.json")
+	content := `{"holidays": [{"date": "2019-10-27", "name": "Deepavali"}, {"date": "2019-12-25", "name": "Christmas Day"}]}`
+	err = ioutil.WriteFile(filename, []byte(content), 0600)
+	assert.Nil(t, err)
+
+	api := &API{
+		Config: &Config{
+			Regions: map[string]*Region{
+				"SG": {TimeZone: "Asia/Singapore", File: filename},
+				"DE": {TimeZone: "Europe/Berlin", ICS: server.URL + "/de.ics"},
+			},
+			Users: map[string]string{
+				"FOO":             "SG",
+				"bar@example.com": "DE",
+			},
+		},
+		HTTPClient: server.Client(),
+	}
+	users := map[string]string{
+		"FOO": "foo@example.com",
+		"BAR": "bar@example.com",
+		"BAZ": "baz@example.com",
+	}
+	start := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	// call
+	result, resultErr := api.GetHolidays(users, start, start.AddDate(0, 1, 0))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result))
+
+	foo := result["FOO"]
+	assert.Equal(t, 1, len(foo))
+	if len(foo) == 1 {
+		assert.Equal(t, "Deepavali", foo[0].Name)
+		assert.Equal(t, "SG", foo[0].Region)
+		assert.Equal(t, time.Date(2019, 10, 26, 16, 0, 0, 0, time.UTC), foo[0].Start.UTC())
+		assert.Equal(t, time.Date(2019, 10, 27, 16, 0, 0, 0, time.UTC), foo[0].End.UTC())
+	}
+
+	bar := result["BAR"]
+	assert.Equal(t, 1, len(bar))
+	if len(bar) == 1 {
+		assert.Equal(t, "Tag der Deutschen Einheit", bar[0].Name)
+		assert.Equal(t, time.Date(2019, 10, 2, 22, 0, 0, 0, time.UTC), bar[0].Start.UTC())
+		assert.Equal(t, time.Date(2019, 10, 3, 22, 0, 0, 0, time.UTC), bar[0].End.UTC())
+	}
+}
+
+func TestAPI_GetHolidays_googleCalendarAcrossTimeZones(t *testing.T) {
+	// inputs
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		requested = req.URL.Path
+		_, _ = resp.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	calendarID := "en.german#holiday@group.v.calendar.google.com"
+	api := &API{
+		Config: &Config{
+			Users: map[string]string{
+				"SG": calendarID,
+				"SF": calendarID,
+				"XX": calendarID,
+			},
+		},
+		// send the requests for the Google feed to the test server
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			target, _ := url.Parse(server.URL)
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		})},
+		TimeZones: map[string]string{
+			"SG": "Asia/Singapore",
+			"SF": "America/Los_Angeles",
+		},
+	}
+	start := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	scenarios := []struct {
+		desc          string
+		inUsers       map[string]string
+		expectedStart map[string]time.Time
+		expectedErr   string
+	}{
+		{
+			desc:    "observed in each user's time zone",
+			inUsers: map[string]string{"SG": "sg@example.com", "SF": "sf@example.com"},
+			expectedStart: map[string]time.Time{
+				"SG": time.Date(2019, 10, 2, 16, 0, 0, 0, time.UTC),
+				"SF": time.Date(2019, 10, 3, 7, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			desc:        "time zone unknown",
+			inUsers:     map[string]string{"XX": "xx@example.com"},
+			expectedErr: "no time zone for 'xx@example.com' to observe '" + calendarID + "' in; assign them to a region with a time_zone",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			result, resultErr := api.GetHolidays(scenario.inUsers, start, start.AddDate(0, 1, 0))
+
+			// validate
+			if scenario.expectedErr != "" {
+				assert.EqualError(t, resultErr, scenario.expectedErr, scenario.desc)
+				return
+			}
+
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, "/calendar/ical/"+calendarID+"/public/basic.ics", requested, scenario.desc)
+			for id, expected := range scenario.expectedStart {
+				assert.Equal(t, 1, len(result[id]), scenario.desc)
+				if len(result[id]) == 1 {
+					assert.Equal(t, expected, result[id][0].Start.UTC(), scenario.desc)
+					assert.Equal(t, expected.Add(24*time.Hour), result[id][0].End.UTC(), scenario.desc)
+				}
+			}
+		})
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (r roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r(req)
+}
+
+func TestLoadConfig(t *testing.T) {
+	scenarios := []struct {
+		desc        string
+		inContent   string
+		expectedErr string
+	}{
+		{
+			desc:      "happy path",
+			inContent: `{"regions": {"SG": {"file": "sg.json"}}, "users": {"FOO": "SG", "bar@example.com": "en.german#holiday@group.v.calendar.google.com"}}`,
+		},
+		{
+			desc:        "unknown region",
+			inContent:   `{"regions": {}, "users": {"FOO": "SG"}}`,
+			expectedErr: "unknown holiday region 'SG' for 'FOO'",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "holidays")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "holidays.json")
+			err = ioutil.WriteFile(filename, []byte(scenario.inContent), 0600)
+			assert.Nil(t, err)
+
+			// call
+			_, resultErr := LoadConfig(filename)
+
+			// validate
+			if scenario.expectedErr == "" {
+				assert.Nil(t, resultErr, scenario.desc)
+			} else {
+				assert.EqualError(t, resultErr, scenario.expectedErr, scenario.desc)
+			}
+		})
+	}
+}
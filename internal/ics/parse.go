@@ -0,0 +1,313 @@
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/gcal"
+)
+
+// Calendar is a parsed iCalendar (RFC 5545) file
+type Calendar struct {
+	// TimeZone is the default time zone of the calendar (X-WR-TIMEZONE) if any
+	TimeZone string
+
+	Events []*Event
+}
+
+// Event is a VEVENT
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Status      string
+	Transparent bool
+	Class       string
+
+	// BusyStatus is the Microsoft busy status (X-MICROSOFT-CDO-BUSYSTATUS); "OOF" is out of office
+	BusyStatus string
+
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+	AllDay   bool
+
+	RRule   string
+	ExDates []time.Time
+
+	// RecurrenceID is set when this event replaces one instance of a recurring event
+	RecurrenceID time.Time
+}
+
+// a content line (e.g. "DTSTART;TZID=Europe/Berlin:20190107T090000")
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads an iCalendar file.  Only the VEVENTs are used; unknown properties and components are ignored
+func Parse(reader io.Reader) (*Calendar, error) {
+	lines, err := unfold(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Calendar{}
+	var current *Event
+	var props []*property
+	depth := 0
+
+	for _, line := range lines {
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			current = &Event{}
+			props = nil
+			depth = 0
+
+		case prop.name == "BEGIN" && current != nil:
+			// nested component (e.g. VALARM)
+			depth++
+
+		case prop.name == "END" && current != nil && depth > 0:
+			depth--
+
+		case prop.name == "END" && strings.EqualFold(prop.value, "VEVENT") && current != nil:
+			err = out.buildEvent(current, props)
+			if err != nil {
+				return nil, err
+			}
+			out.Events = append(out.Events, current)
+			current = nil
+
+		case prop.name == "X-WR-TIMEZONE" && current == nil:
+			out.TimeZone = prop.value
+
+		case current != nil && depth == 0:
+			props = append(props, prop)
+		}
+	}
+
+	return out, nil
+}
+
+// dates are parsed after the whole event is read, using the calendar's default time zone
+func (c *Calendar) buildEvent(event *Event, props []*property) error {
+	location := gcal.LoadLocation(c.TimeZone, time.UTC)
+	hasEnd := false
+
+	for _, prop := range props {
+		var err error
+
+		switch prop.name {
+		case "UID":
+			event.UID = prop.value
+		case "SUMMARY":
+			event.Summary = unescape(prop.value)
+		case "DESCRIPTION":
+			event.Description = unescape(prop.value)
+		case "STATUS":
+			event.Status = strings.ToUpper(prop.value)
+		case "TRANSP":
+			event.Transparent = strings.EqualFold(prop.value, "TRANSPARENT")
+		case "CLASS":
+			event.Class = strings.ToUpper(prop.value)
+		case "X-MICROSOFT-CDO-BUSYSTATUS":
+			event.BusyStatus = strings.ToUpper(prop.value)
+		case "RRULE":
+			event.RRule = prop.value
+		case "DTSTART":
+			event.Start, event.AllDay, err = parseTime(prop, location)
+		case "DTEND":
+			event.End, _, err = parseTime(prop, location)
+			hasEnd = true
+		case "DURATION":
+			event.Duration, err = parseDuration(prop.value)
+		case "RECURRENCE-ID":
+			event.RecurrenceID, _, err = parseTime(prop, location)
+		case "EXDATE":
+			for _, value := range strings.Split(prop.value, ",") {
+				var exDate time.Time
+				exDate, _, err = parseTime(&property{name: prop.name, params: prop.params, value: value}, location)
+				if err != nil {
+					break
+				}
+				event.ExDates = append(event.ExDates, exDate)
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to parse %s of event '%s': %s", prop.name, event.UID, err)
+		}
+	}
+
+	if event.Start.IsZero() {
+		return fmt.Errorf("event '%s' has no DTSTART", event.UID)
+	}
+
+	switch {
+	case hasEnd:
+		event.Duration = event.End.Sub(event.Start)
+	case event.Duration != 0:
+		event.End = event.Start.Add(event.Duration)
+	case event.AllDay:
+		// an all-day event without an end lasts for 1 day
+		event.End = event.Start.AddDate(0, 0, 1)
+		event.Duration = event.End.Sub(event.Start)
+	default:
+		event.End = event.Start
+	}
+
+	return nil
+}
+
+// joins the folded lines (lines that start with a space or tab continue the previous line)
+func unfold(reader io.Reader) ([]string, error) {
+	var out []string
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(out) > 0 {
+			out[len(out)-1] += line[1:]
+			continue
+		}
+
+		out = append(out, line)
+	}
+
+	return out, scanner.Err()
+}
+
+func parseProperty(line string) (*property, error) {
+	// find the ":" that separates the value (ignoring any inside quoted parameter values)
+	inQuotes := false
+	split := -1
+	for x, char := range line {
+		if char == '"' {
+			inQuotes = !inQuotes
+		}
+		if char == ':' && !inQuotes {
+			split = x
+			break
+		}
+	}
+
+	if split < 0 {
+		return nil, fmt.Errorf("invalid line '%s'", line)
+	}
+
+	out := &property{
+		params: map[string]string{},
+		value:  line[split+1:],
+	}
+
+	parts := strings.Split(line[:split], ";")
+	out.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		keyValue := strings.SplitN(param, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+		out.params[strings.ToUpper(keyValue[0])] = strings.Trim(keyValue[1], `"`)
+	}
+
+	return out, nil
+}
+
+// returns the time and whether it is a date (all-day) value
+func parseTime(prop *property, location *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.value)
+	if tzid, found := prop.params["TZID"]; found {
+		location = gcal.LoadLocation(tzid, location)
+	}
+
+	if prop.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		out, err := time.ParseInLocation("20060102", value, location)
+		return out, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		out, err := time.Parse("20060102T150405Z", value)
+		return out, false, err
+	}
+
+	// floating or TZID time
+	out, err := time.ParseInLocation("20060102T150405", value, location)
+	return out, false, err
+}
+
+// parses durations like "PT1H30M", "P1D" or "-P1W"
+func parseDuration(value string) (time.Duration, error) {
+	original := value
+	sign := time.Duration(1)
+
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign = -1
+		value = value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("invalid duration '%s'", original)
+	}
+	value = value[1:]
+
+	var out time.Duration
+	number := 0
+	hasNumber := false
+	for _, char := range value {
+		switch {
+		case char >= '0' && char <= '9':
+			number = number*10 + int(char-'0')
+			hasNumber = true
+			continue
+		case char == 'T':
+			continue
+		}
+
+		if !hasNumber {
+			return 0, fmt.Errorf("invalid duration '%s'", original)
+		}
+
+		switch char {
+		case 'W':
+			out += time.Duration(number) * 7 * 24 * time.Hour
+		case 'D':
+			out += time.Duration(number) * 24 * time.Hour
+		case 'H':
+			out += time.Duration(number) * time.Hour
+		case 'M':
+			out += time.Duration(number) * time.Minute
+		case 'S':
+			out += time.Duration(number) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration '%s'", original)
+		}
+
+		number = 0
+		hasNumber = false
+	}
+
+	return sign * out, nil
+}
+
+func unescape(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,155 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"X-WR-TIMEZONE:Europe/Berlin\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:1\r\n" +
+	"SUMMARY:Out - long weekend\\, back Tuesday\r\n" +
+	"DESCRIPTION:first line\\nsecond line that has been \r\n" +
+	" folded\r\n" +
+	"DTSTART;VALUE=DATE:20190104\r\n" +
+	"DTEND;VALUE=DATE:20190108\r\n" +
+	"CLASS:PRIVATE\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"DESCRIPTION:reminder\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:2\r\n" +
+	"SUMMARY:Dentist\r\n" +
+	"DTSTART;TZID=\"America/New_York\":20190110T090000\r\n" +
+	"DURATION:PT1H30M\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"X-MICROSOFT-CDO-BUSYSTATUS:OOF\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:3\r\n" +
+	"SUMMARY:Floating\r\n" +
+	"DTSTART:20190111T090000\r\n" +
+	"DTEND:20190111T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+
+	// call
+	result, resultErr := Parse(strings.NewReader(testCalendar))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, "Europe/Berlin", result.TimeZone)
+	assert.Equal(t, 3, len(result.Events))
+	if len(result.Events) != 3 {
+		return
+	}
+
+	allDay := result.Events[0]
+	assert.Equal(t, "Out - long weekend, back Tuesday", allDay.Summary)
+	assert.Equal(t, "first line\nsecond line that has been folded", allDay.Description)
+	assert.True(t, allDay.AllDay)
+	assert.True(t, time.Date(2019, 1, 4, 0, 0, 0, 0, berlin).Equal(allDay.Start))
+	assert.True(t, time.Date(2019, 1, 8, 0, 0, 0, 0, berlin).Equal(allDay.End))
+	assert.Equal(t, "PRIVATE", allDay.Class)
+
+	withDuration := result.Events[1]
+	assert.True(t, time.Date(2019, 1, 10, 9, 0, 0, 0, newYork).Equal(withDuration.Start))
+	assert.True(t, time.Date(2019, 1, 10, 10, 30, 0, 0, newYork).Equal(withDuration.End))
+	assert.True(t, withDuration.Transparent)
+	assert.Equal(t, "OOF", withDuration.BusyStatus)
+
+	floating := result.Events[2]
+	assert.True(t, time.Date(2019, 1, 11, 9, 0, 0, 0, berlin).Equal(floating.Start))
+	assert.True(t, time.Date(2019, 1, 11, 10, 0, 0, 0, time.UTC).Equal(floating.End))
+}
+
+func TestParse_invalid(t *testing.T) {
+	scenarios := []struct {
+		desc    string
+		inInput string
+	}{
+		{
+			desc:    "line without a value",
+			inInput: "BEGIN:VCALENDAR\nNONSENSE\nEND:VCALENDAR\n",
+		},
+		{
+			desc:    "event without a start",
+			inInput: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\nEND:VEVENT\nEND:VCALENDAR\n",
+		},
+		{
+			desc:    "invalid date",
+			inInput: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\nDTSTART:2019-01-01\nEND:VEVENT\nEND:VCALENDAR\n",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			_, resultErr := Parse(strings.NewReader(scenario.inInput))
+
+			// validate
+			assert.NotNil(t, resultErr, scenario.desc)
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	scenarios := []struct {
+		desc        string
+		inValue     string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{
+			desc:     "hours and minutes",
+			inValue:  "PT1H30M",
+			expected: 90 * time.Minute,
+		},
+		{
+			desc:     "days",
+			inValue:  "P2D",
+			expected: 48 * time.Hour,
+		},
+		{
+			desc:     "weeks",
+			inValue:  "P1W",
+			expected: 7 * 24 * time.Hour,
+		},
+		{
+			desc:     "negative",
+			inValue:  "-PT15M",
+			expected: -15 * time.Minute,
+		},
+		{
+			desc:        "invalid",
+			inValue:     "1H",
+			expectedErr: true,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			result, resultErr := parseDuration(scenario.inValue)
+
+			// validate
+			assert.Equal(t, scenario.expectedErr, resultErr != nil, scenario.desc)
+			assert.Equal(t, scenario.expected, result, scenario.desc)
+		})
+	}
+}
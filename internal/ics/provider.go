@@ -0,0 +1,167 @@
+package ics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/gcal"
+)
+
+const (
+	// ProviderName is the name used to assign users to this provider
+	ProviderName = "ics"
+)
+
+// Provider loads calendars from iCalendar files or HTTP(S) feeds
+type Provider struct {
+	// Sources are the paths or URLs of each user's calendar (mapped by PD user id or email)
+	Sources map[string]string
+
+	// Rules decide which events mean the user is unavailable (default: gcal.DefaultRules())
+	Rules []*gcal.Rule
+
+	// HTTPClient is used for feeds (default: a client with a 60 second timeout)
+	HTTPClient *http.Client
+}
+
+// GetCalendars implements gcal.Provider
+func (p *Provider) GetCalendars(users map[string]string, start time.Time, end time.Time) (map[string]*gcal.Calendar, error) {
+	rules := p.Rules
+	if len(rules) == 0 {
+		rules = gcal.DefaultRules()
+	}
+
+	err := gcal.CompileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]*gcal.Calendar{}
+
+	// a calendar that could not be loaded is returned with Err set rather than failing the other users
+	for id, email := range users {
+		source := p.source(id, email)
+		if source == "" {
+			out[id] = &gcal.Calendar{Err: fmt.Errorf("no ICS source configured for '%s'", email)}
+			continue
+		}
+
+		calendar, err := p.getCalendar(source, rules, start, end)
+		if err != nil {
+			out[id] = &gcal.Calendar{Err: fmt.Errorf("failed to load ICS calendar for '%s': %s", email, err)}
+			continue
+		}
+
+		out[id] = calendar
+	}
+
+	return out, nil
+}
+
+func (p *Provider) getCalendar(source string, rules []*gcal.Rule, start time.Time, end time.Time) (*gcal.Calendar, error) {
+	reader, err := Open(source, p.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	parsed, err := Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences, err := parsed.Expand(start.AddDate(0, 0, -1), end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	out := &gcal.Calendar{TimeZone: parsed.TimeZone}
+	for _, occurrence := range occurrences {
+		event := toEvent(occurrence)
+
+		rule := gcal.MatchRule(rules, event)
+		if rule == nil {
+			continue
+		}
+
+		out.Items = append(out.Items, gcal.NewCalendarItem(event, rule))
+	}
+
+	return out, nil
+}
+
+// returns the source for the user (by PD user id first)
+func (p *Provider) source(id string, email string) string {
+	if source, found := p.Sources[id]; found {
+		return source
+	}
+
+	return p.Sources[email]
+}
+
+// Open returns the content of an iCalendar file, HTTP(S) or webcal feed
+// (client defaults to a client with a 60 second timeout)
+func Open(source string, client *http.Client) (io.ReadCloser, error) {
+	if strings.HasPrefix(source, "webcal://") {
+		source = "https://" + strings.TrimPrefix(source, "webcal://")
+	}
+
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// convert the occurrence into the provider independent form
+func toEvent(occurrence *Occurrence) *gcal.Event {
+	event := occurrence.Event
+
+	out := &gcal.Event{
+		ID:           event.UID,
+		Summary:      event.Summary,
+		Description:  event.Description,
+		Transparency: "opaque",
+		Visibility:   "default",
+		Start:        occurrence.Start,
+		End:          occurrence.End,
+		AllDay:       event.AllDay,
+	}
+
+	if event.Transparent {
+		out.Transparency = "transparent"
+	}
+
+	switch event.Class {
+	case "PUBLIC":
+		out.Visibility = "public"
+	case "PRIVATE":
+		out.Visibility = "private"
+	case "CONFIDENTIAL":
+		out.Visibility = "confidential"
+	}
+
+	if event.BusyStatus == "OOF" {
+		out.EventType = gcal.EventTypeOutOfOffice
+	}
+
+	return out
+}
@@ -0,0 +1,127 @@
+package ics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/gcal"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFeed = "BEGIN:VCALENDAR\n" +
+	"X-WR-TIMEZONE:Europe/Berlin\n" +
+	"BEGIN:VEVENT\n" +
+	"UID:1\n" +
+	"SUMMARY:Workout\n" +
+	"DTSTART;TZID=Europe/Berlin:20190107T070000\n" +
+	"DTEND;TZID=Europe/Berlin:20190107T080000\n" +
+	"RRULE:FREQ=DAILY\n" +
+	"END:VEVENT\n" +
+	"BEGIN:VEVENT\n" +
+	"UID:2\n" +
+	"SUMMARY:Abwesend\n" +
+	"DTSTART;VALUE=DATE:20190110\n" +
+	"DTEND;VALUE=DATE:20190112\n" +
+	"END:VEVENT\n" +
+	"BEGIN:VEVENT\n" +
+	"UID:3\n" +
+	"SUMMARY:Leave\n" +
+	"X-MICROSOFT-CDO-BUSYSTATUS:OOF\n" +
+	"DTSTART;VALUE=DATE:20190115\n" +
+	"END:VEVENT\n" +
+	"END:VCALENDAR\n"
+
+func TestProvider_GetCalendars(t *testing.T) {
+	// inputs
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/bar.ics" {
+			http.NotFound(resp, req)
+			return
+		}
+		_, _ = resp.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "ics")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "foo.ics")
+	err = ioutil.WriteFile(filename, []byte(testFeed), 0600)
+	assert.Nil(t, err)
+
+	provider := &Provider{
+		Sources: map[string]string{
+			"FOO":             filename,
+			"bar@example.com": server.URL + "/bar.ics",
+		},
+		Rules: []*gcal.Rule{
+			{Name: "out of office", Keywords: []string{"abwesend"}, EventTypes: []string{gcal.EventTypeOutOfOffice}},
+		},
+		HTTPClient: server.Client(),
+	}
+	users := map[string]string{
+		"FOO": "foo@example.com",
+		"BAR": "bar@example.com",
+	}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// call
+	result, resultErr := provider.GetCalendars(users, start, start.AddDate(0, 1, 0))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result))
+	for _, id := range []string{"FOO", "BAR"} {
+		assert.Equal(t, "Europe/Berlin", result[id].TimeZone, id)
+		assert.Equal(t, 2, len(result[id].Items), id)
+		if len(result[id].Items) == 2 {
+			assert.Equal(t, time.Date(2019, 1, 9, 23, 0, 0, 0, time.UTC), result[id].Items[0].Start.UTC(), id)
+			assert.Equal(t, time.Date(2019, 1, 11, 23, 0, 0, 0, time.UTC), result[id].Items[0].End.UTC(), id)
+			assert.Equal(t, "out of office", result[id].Items[1].Rule, id)
+		}
+	}
+}
+
+func TestProvider_GetCalendars_errors(t *testing.T) {
+	// inputs
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	scenarios := []struct {
+		desc        string
+		inSources   map[string]string
+		expectedErr string
+	}{
+		{
+			desc:        "no source",
+			inSources:   map[string]string{},
+			expectedErr: "no ICS source configured for 'foo@example.com'",
+		},
+		{
+			desc:        "feed not found",
+			inSources:   map[string]string{"FOO": server.URL + "/foo.ics"},
+			expectedErr: "failed to load ICS calendar for 'foo@example.com': unexpected status 404",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			provider := &Provider{Sources: scenario.inSources, HTTPClient: server.Client()}
+			start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			// call
+			result, resultErr := provider.GetCalendars(map[string]string{"FOO": "foo@example.com"}, start, start.AddDate(0, 1, 0))
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.EqualError(t, result["FOO"].Err, scenario.expectedErr, scenario.desc)
+		})
+	}
+}
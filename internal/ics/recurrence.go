@@ -0,0 +1,370 @@
+package ics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// guard against rules that never produce an occurrence in the period
+	maxPeriods = 100000
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Occurrence is a single instance of an event
+type Occurrence struct {
+	Event *Event
+	Start time.Time
+	End   time.Time
+}
+
+// a weekday with an optional ordinal (e.g. "-1FR" is the last Friday)
+type byDay struct {
+	ordinal int
+	weekday time.Weekday
+}
+
+// the supported subset of an RRULE (FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH)
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []byDay
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+// Expand returns the occurrences of all events that overlap the period (sorted by start).
+// Recurring events are expanded, excluding the EXDATEs and replacing the instances that have their own VEVENT
+// (RECURRENCE-ID). Cancelled events are ignored
+func (c *Calendar) Expand(start time.Time, end time.Time) ([]*Occurrence, error) {
+	// find the instances that have been replaced (mapped by UID)
+	replaced := map[string][]time.Time{}
+	for _, event := range c.Events {
+		if !event.RecurrenceID.IsZero() {
+			replaced[event.UID] = append(replaced[event.UID], event.RecurrenceID)
+		}
+	}
+
+	var out []*Occurrence
+	for _, event := range c.Events {
+		if event.Status == "CANCELLED" {
+			continue
+		}
+
+		if event.RRule == "" || !event.RecurrenceID.IsZero() {
+			if event.Start.Before(end) && event.End.After(start) {
+				out = append(out, &Occurrence{Event: event, Start: event.Start, End: event.End})
+			}
+			continue
+		}
+
+		rule, err := parseRRule(event.RRule, event.Start.Location())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RRULE of event '%s': %s", event.UID, err)
+		}
+
+		excluded := append(append([]time.Time{}, event.ExDates...), replaced[event.UID]...)
+		for _, occurrenceStart := range rule.expand(event.Start, start.Add(-event.Duration), end) {
+			if containsTime(excluded, occurrenceStart) {
+				continue
+			}
+
+			occurrenceEnd := occurrenceStart.Add(event.Duration)
+			if event.AllDay {
+				// all-day events last the same number of days (not hours) to allow for daylight saving changes
+				occurrenceEnd = occurrenceStart.AddDate(0, 0, int((event.Duration+12*time.Hour)/(24*time.Hour)))
+			}
+
+			if occurrenceEnd.After(start) {
+				out = append(out, &Occurrence{Event: event, Start: occurrenceStart, End: occurrenceEnd})
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Start.Before(out[j].Start)
+	})
+
+	return out, nil
+}
+
+func parseRRule(value string, location *time.Location) (*rrule, error) {
+	out := &rrule{interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+		key, value := strings.ToUpper(keyValue[0]), strings.ToUpper(keyValue[1])
+
+		var err error
+		switch key {
+		case "FREQ":
+			out.freq = value
+		case "INTERVAL":
+			out.interval, err = strconv.Atoi(value)
+		case "COUNT":
+			out.count, err = strconv.Atoi(value)
+		case "UNTIL":
+			out.until, _, err = parseTime(&property{value: value, params: map[string]string{}}, location)
+			if err == nil && len(value) == len("20060102") {
+				// an UNTIL date includes the whole day
+				out.until = out.until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		case "BYDAY":
+			out.byDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			out.byMonthDay, err = parseInts(value)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(value)
+			for _, month := range months {
+				out.byMonth = append(out.byMonth, time.Month(month))
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s'", key, value)
+		}
+	}
+
+	switch out.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported FREQ '%s'", out.freq)
+	}
+
+	if out.interval < 1 {
+		return nil, fmt.Errorf("invalid INTERVAL '%d'", out.interval)
+	}
+
+	return out, nil
+}
+
+// returns the occurrence start times that are before end and not before start
+func (r *rrule) expand(dtStart time.Time, start time.Time, end time.Time) []time.Time {
+	var out []time.Time
+	count := 0
+
+	for period := 0; period < maxPeriods; period++ {
+		candidates, periodStart := r.candidates(dtStart, period)
+		if !periodStart.Before(end) {
+			break
+		}
+
+		for _, candidate := range candidates {
+			if candidate.Before(dtStart) {
+				continue
+			}
+
+			if !r.until.IsZero() && candidate.After(r.until) {
+				return out
+			}
+
+			count++
+			if r.count > 0 && count > r.count {
+				return out
+			}
+
+			if !candidate.Before(start) && candidate.Before(end) {
+				out = append(out, candidate)
+			}
+		}
+	}
+
+	return out
+}
+
+// returns the (sorted) candidate occurrences for the period and the start of the period
+func (r *rrule) candidates(dtStart time.Time, period int) ([]time.Time, time.Time) {
+	location := dtStart.Location()
+	hour, minute, second := dtStart.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, location)
+	}
+
+	var days []time.Time
+	var periodStart time.Time
+
+	switch r.freq {
+	case "DAILY":
+		periodStart = dtStart.AddDate(0, 0, period*r.interval)
+		days = []time.Time{periodStart}
+
+	case "WEEKLY":
+		// weeks start on Monday
+		offset := (int(dtStart.Weekday()) + 6) % 7
+		monday := at(dtStart.Year(), dtStart.Month(), dtStart.Day()-offset+period*r.interval*7)
+		periodStart = monday
+
+		if len(r.byDay) == 0 {
+			days = []time.Time{monday.AddDate(0, 0, offset)}
+		}
+		for _, day := range r.byDay {
+			days = append(days, monday.AddDate(0, 0, (int(day.weekday)+6)%7))
+		}
+
+	case "MONTHLY":
+		first := at(dtStart.Year(), dtStart.Month()+time.Month(period*r.interval), 1)
+		periodStart = first
+		days = r.daysInMonth(first, dtStart.Day())
+
+	case "YEARLY":
+		year := dtStart.Year() + period*r.interval
+		periodStart = at(year, time.January, 1)
+
+		months := r.byMonth
+		if len(months) == 0 {
+			months = []time.Month{dtStart.Month()}
+		}
+		for _, month := range months {
+			days = append(days, r.daysInMonth(at(year, month, 1), dtStart.Day())...)
+		}
+	}
+
+	var out []time.Time
+	for _, day := range days {
+		if len(r.byMonth) > 0 && !containsMonth(r.byMonth, day.Month()) {
+			continue
+		}
+
+		if r.freq == "DAILY" && len(r.byDay) > 0 && !r.matchesWeekday(day) {
+			continue
+		}
+
+		out = append(out, day)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Before(out[j])
+	})
+
+	return out, periodStart
+}
+
+// returns the matching days in the month starting at first
+func (r *rrule) daysInMonth(first time.Time, defaultDay int) []time.Time {
+	lastDay := first.AddDate(0, 1, -1).Day()
+	var out []time.Time
+
+	for _, monthDay := range r.byMonthDay {
+		if monthDay < 0 {
+			monthDay = lastDay + monthDay + 1
+		}
+		if monthDay >= 1 && monthDay <= lastDay {
+			out = append(out, first.AddDate(0, 0, monthDay-1))
+		}
+	}
+
+	for _, day := range r.byDay {
+		var matching []time.Time
+		for monthDay := 1; monthDay <= lastDay; monthDay++ {
+			thisDay := first.AddDate(0, 0, monthDay-1)
+			if thisDay.Weekday() == day.weekday {
+				matching = append(matching, thisDay)
+			}
+		}
+
+		switch {
+		case day.ordinal == 0:
+			out = append(out, matching...)
+		case day.ordinal > 0 && day.ordinal <= len(matching):
+			out = append(out, matching[day.ordinal-1])
+		case day.ordinal < 0 && -day.ordinal <= len(matching):
+			out = append(out, matching[len(matching)+day.ordinal])
+		}
+	}
+
+	if len(r.byMonthDay) == 0 && len(r.byDay) == 0 && defaultDay <= lastDay {
+		out = append(out, first.AddDate(0, 0, defaultDay-1))
+	}
+
+	return out
+}
+
+func (r *rrule) matchesWeekday(day time.Time) bool {
+	for _, thisDay := range r.byDay {
+		if thisDay.weekday == day.Weekday() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseByDay(value string) ([]byDay, error) {
+	var out []byDay
+
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY '%s'", item)
+		}
+
+		weekday, found := weekdays[item[len(item)-2:]]
+		if !found {
+			return nil, fmt.Errorf("invalid BYDAY '%s'", item)
+		}
+
+		ordinal := 0
+		if len(item) > 2 {
+			var err error
+			ordinal, err = strconv.Atoi(item[:len(item)-2])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		out = append(out, byDay{ordinal: ordinal, weekday: weekday})
+	}
+
+	return out, nil
+}
+
+func parseInts(value string) ([]int, error) {
+	var out []int
+
+	for _, item := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, number)
+	}
+
+	return out, nil
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, thisMonth := range months {
+		if thisMonth == month {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsTime(times []time.Time, value time.Time) bool {
+	for _, thisTime := range times {
+		if thisTime.Equal(value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,191 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendar_Expand(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	newCalendar := func(events ...string) string {
+		return "BEGIN:VCALENDAR\n" + strings.Join(events, "") + "END:VCALENDAR\n"
+	}
+	newEvent := func(lines ...string) string {
+		return "BEGIN:VEVENT\n" + strings.Join(lines, "\n") + "\nEND:VEVENT\n"
+	}
+	inBerlin := func(month time.Month, day int, hour int) time.Time {
+		return time.Date(2019, month, day, hour, 0, 0, 0, berlin)
+	}
+
+	scenarios := []struct {
+		desc           string
+		inCalendar     string
+		inStart        time.Time
+		inEnd          time.Time
+		expectedStarts []time.Time
+		expectedEnds   []time.Time
+	}{
+		{
+			desc: "daily with count",
+			inCalendar: newCalendar(newEvent(
+				"UID:1",
+				"DTSTART;TZID=Europe/Berlin:20190101T090000",
+				"DTEND;TZID=Europe/Berlin:20190101T100000",
+				"RRULE:FREQ=DAILY;COUNT=3",
+			)),
+			inStart:        inBerlin(time.January, 1, 0),
+			inEnd:          inBerlin(time.February, 1, 0),
+			expectedStarts: []time.Time{inBerlin(time.January, 1, 9), inBerlin(time.January, 2, 9), inBerlin(time.January, 3, 9)},
+		},
+		{
+			desc: "daily - only occurrences in the period",
+			inCalendar: newCalendar(newEvent(
+				"UID:1",
+				"DTSTART;TZID=Europe/Berlin:20180101T090000",
+				"DTEND;TZID=Europe/Berlin:20180101T100000",
+				"RRULE:FREQ=DAILY;INTERVAL=2",
+			)),
+			inStart:        inBerlin(time.January, 10, 0),
+			inEnd:          inBerlin(time.January, 14, 0),
+			expectedStarts: []time.Time{inBerlin(time.January, 10, 9), inBerlin(time.January, 12, 9)},
+		},
+		{
+			desc: "weekly on weekdays until - keeps the local time across daylight saving",
+			inCalendar: newCalendar(newEvent(
+				"UID:1",
+				"DTSTART;TZID=Europe/Berlin:20190328T090000",
+				"DTEND;TZID=Europe/Berlin:20190328T170000",
+				"RRULE:FREQ=WEEKLY;BYDAY=TH,FR;UNTIL=20190405T235959Z",
+			)),
+			inStart:        inBerlin(time.March, 1, 0),
+			inEnd:          inBerlin(time.May, 1, 0),
+			expectedStarts: []time.Time{inBerlin(time.March, 28, 9), inBerlin(time.March, 29, 9), inBerlin(time.April, 4, 9), inBerlin(time.April, 5, 9)},
+		},
+		{
+			desc: "monthly on the last Friday with an EXDATE",
+			inCalendar: newCalendar(newEvent(
+				"UID:1",
+				"DTSTART;TZID=Europe/Berlin:20190125T130000",
+				"DTEND;TZID=Europe/Berlin:20190125T180000",
+				"RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+				"EXDATE;TZID=Europe/Berlin:20190222T130000",
+			)),
+			inStart:        inBerlin(time.January, 1, 0),
+			inEnd:          inBerlin(time.April, 1, 0),
+			expectedStarts: []time.Time{inBerlin(time.January, 25, 13), inBerlin(time.March, 29, 13)},
+		},
+		{
+			desc: "yearly all-day event without a time zone is in UTC",
+			inCalendar: newCalendar(newEvent(
+				"UID:1",
+				"DTSTART;VALUE=DATE:20180331",
+				"DTEND;VALUE=DATE:20180401",
+				"RRULE:FREQ=YEARLY",
+			)),
+			inStart:        time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+			inEnd:          time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC),
+			expectedStarts: []time.Time{time.Date(2019, 3, 31, 0, 0, 0, 0, time.UTC)},
+			expectedEnds:   []time.Time{time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			desc: "all-day recurring event across daylight saving lasts 23 hours",
+			inCalendar: "BEGIN:VCALENDAR\nX-WR-TIMEZONE:Europe/Berlin\n" + newEvent(
+				"UID:1",
+				"DTSTART;VALUE=DATE:20190330",
+				"DTEND;VALUE=DATE:20190331",
+				"RRULE:FREQ=DAILY;COUNT=2",
+			) + "END:VCALENDAR\n",
+			inStart:        inBerlin(time.March, 1, 0),
+			inEnd:          inBerlin(time.May, 1, 0),
+			expectedStarts: []time.Time{inBerlin(time.March, 30, 0), inBerlin(time.March, 31, 0)},
+			expectedEnds:   []time.Time{inBerlin(time.March, 31, 0), inBerlin(time.April, 1, 0)},
+		},
+		{
+			desc: "moved instance replaces the original and cancelled events are ignored",
+			inCalendar: newCalendar(
+				newEvent(
+					"UID:1",
+					"DTSTART:20190107T090000Z",
+					"DTEND:20190107T100000Z",
+					"RRULE:FREQ=WEEKLY;COUNT=3",
+				),
+				newEvent(
+					"UID:1",
+					"RECURRENCE-ID:20190114T090000Z",
+					"DTSTART:20190115T090000Z",
+					"DTEND:20190115T100000Z",
+				),
+				newEvent(
+					"UID:2",
+					"STATUS:CANCELLED",
+					"DTSTART:20190108T090000Z",
+					"DTEND:20190108T100000Z",
+				),
+			),
+			inStart: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			inEnd:   time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC),
+			expectedStarts: []time.Time{
+				time.Date(2019, 1, 7, 9, 0, 0, 0, time.UTC),
+				time.Date(2019, 1, 15, 9, 0, 0, 0, time.UTC),
+				time.Date(2019, 1, 21, 9, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			calendar, err := Parse(strings.NewReader(scenario.inCalendar))
+			assert.Nil(t, err, scenario.desc)
+
+			// call
+			result, resultErr := calendar.Expand(scenario.inStart, scenario.inEnd)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, len(scenario.expectedStarts), len(result), scenario.desc)
+			for x := 0; x < len(result) && x < len(scenario.expectedStarts); x++ {
+				assert.True(t, scenario.expectedStarts[x].Equal(result[x].Start), scenario.desc)
+				if scenario.expectedEnds != nil {
+					assert.True(t, scenario.expectedEnds[x].Equal(result[x].End), scenario.desc)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRRule_invalid(t *testing.T) {
+	scenarios := []struct {
+		desc    string
+		inValue string
+	}{
+		{
+			desc:    "unsupported frequency",
+			inValue: "FREQ=SECONDLY",
+		},
+		{
+			desc:    "invalid interval",
+			inValue: "FREQ=DAILY;INTERVAL=0",
+		},
+		{
+			desc:    "invalid weekday",
+			inValue: "FREQ=WEEKLY;BYDAY=XX",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			_, resultErr := parseRRule(scenario.inValue, time.UTC)
+
+			// validate
+			assert.NotNil(t, resultErr, scenario.desc)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Mapper converts the emails of PagerDuty users into their calendar emails
+type Mapper struct {
+	// Users maps a PD user id or email to the calendar email; these take priority over the other rules
+	Users map[string]string `json:"users"`
+
+	// Rewrites are tried in order; the first that matches the PD email is used
+	Rewrites []*Rewrite `json:"rewrites"`
+
+	// Aliases maps a calendar (primary) email to its other addresses
+	Aliases map[string][]string `json:"aliases"`
+
+	primaries map[string]string
+}
+
+// Rewrite converts emails that match the regex (e.g. "^(\\w)\\w*\\.(\\w+)@corp\\.com$") using the replacement
+// (e.g. "${1}${2}@corp.com")
+type Rewrite struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+
+	regex *regexp.Regexp
+}
+
+// LoadMapper reads the mapping from a JSON file
+func LoadMapper(file string) (*Mapper, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Mapper{}
+	err = json.Unmarshal(contents, out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity mapping file '%s': %s", file, err)
+	}
+
+	return out, nil
+}
+
+// Map returns the calendar email of each user (users maps PD user ids to PD emails).
+// Users that are not matched by any mapping, rewrite, alias or primary email keep their PD email and are returned as unmapped
+// (PD user ids, sorted)
+func (m *Mapper) Map(users map[string]string) (map[string]string, []string, error) {
+	err := m.compile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := map[string]string{}
+	var unmapped []string
+
+	for id, email := range users {
+		mapped, found := m.mapUser(id, email)
+		if !found {
+			unmapped = append(unmapped, id)
+		}
+
+		out[id] = mapped
+	}
+
+	sort.Strings(unmapped)
+
+	return out, unmapped, nil
+}
+
+// returns the calendar email and whether any of the rules applied
+func (m *Mapper) mapUser(id string, email string) (string, bool) {
+	if mapped, found := m.Users[id]; found {
+		return mapped, true
+	}
+
+	key := strings.ToLower(email)
+
+	for pdEmail, mapped := range m.Users {
+		if strings.ToLower(pdEmail) == key {
+			return mapped, true
+		}
+	}
+
+	if key == "" {
+		return email, false
+	}
+
+	for _, rewrite := range m.Rewrites {
+		if rewrite.regex.MatchString(key) {
+			// the rewritten email may itself be an alias
+			rewritten := rewrite.regex.ReplaceAllString(key, rewrite.Replace)
+			if primary, found := m.primaries[rewritten]; found {
+				return primary, true
+			}
+			return rewritten, true
+		}
+	}
+
+	if primary, found := m.primaries[key]; found {
+		return primary, true
+	}
+
+	return email, false
+}
+
+func (m *Mapper) compile() error {
+	for _, rewrite := range m.Rewrites {
+		if rewrite.regex != nil {
+			continue
+		}
+
+		regex, err := regexp.Compile("(?i)" + rewrite.Match)
+		if err != nil {
+			return fmt.Errorf("invalid rewrite '%s': %s", rewrite.Match, err)
+		}
+		rewrite.regex = regex
+	}
+
+	// primary emails are already correct
+	m.primaries = map[string]string{}
+	for primary, aliases := range m.Aliases {
+		m.primaries[strings.ToLower(primary)] = primary
+
+		for _, alias := range aliases {
+			m.primaries[strings.ToLower(alias)] = primary
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package identity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapper_Map(t *testing.T) {
+	// inputs
+	mapper := &Mapper{
+		Users: map[string]string{
+			"PCONTRACTOR":     "contractor@agency.example.com",
+			"bob@example.com": "bob@corp.com",
+		},
+		Rewrites: []*Rewrite{
+			{Match: `^(\w)\w*\.(\w+)@corp\.com$`, Replace: "${1}${2}@corp.com"},
+			{Match: `^(.+)@corp\.io$`, Replace: "${1}@corp.com"},
+		},
+		Aliases: map[string][]string{
+			"jsmith@corp.com": {"john@corp.com", "jsmith@corp.io"},
+		},
+	}
+	users := map[string]string{
+		"PCONTRACTOR": "someone@gmail.com",
+		"PBOB":        "Bob@Example.com",
+		"PALICE":      "alice.jones@corp.com",
+		"PJOHN":       "john@corp.com",
+		"PSMITH":      "jsmith@corp.io",
+		"PPRIMARY":    "JSmith@corp.com",
+		"PNOBODY":     "nobody@elsewhere.com",
+		"PNOEMAIL":    "",
+	}
+
+	// call
+	result, unmapped, resultErr := mapper.Map(users)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, map[string]string{
+		"PCONTRACTOR": "contractor@agency.example.com",
+		"PBOB":        "bob@corp.com",
+		"PALICE":      "ajones@corp.com",
+		"PJOHN":       "jsmith@corp.com",
+		"PSMITH":      "jsmith@corp.com",
+		"PPRIMARY":    "jsmith@corp.com",
+		"PNOBODY":     "nobody@elsewhere.com",
+		"PNOEMAIL":    "",
+	}, result)
+	assert.Equal(t, []string{"PNOBODY", "PNOEMAIL"}, unmapped)
+}
+
+func TestMapper_Map_invalidRewrite(t *testing.T) {
+	// inputs
+	mapper := &Mapper{Rewrites: []*Rewrite{{Match: "(", Replace: ""}}}
+
+	// call
+	_, _, resultErr := mapper.Map(map[string]string{"FOO": "foo@example.com"})
+
+	// validate
+	assert.NotNil(t, resultErr)
+}
+
+func TestLoadMapper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "identity.json")
+	content := `{
+		"users": {"PABC123": "alice@corp.com"},
+		"rewrites": [{"match": "^(.+)@old\\.example\\.com$", "replace": "${1}@example.com"}],
+		"aliases": {"flast@corp.com": ["first.last@corp.com"]}
+	}`
+	err = ioutil.WriteFile(filename, []byte(content), 0600)
+	assert.Nil(t, err)
+
+	// call
+	mapper, resultErr := LoadMapper(filename)
+
+	// validate
+	assert.Nil(t, resultErr)
+	result, unmapped, err := mapper.Map(map[string]string{
+		"PABC123": "alice@example.com",
+		"PDEF456": "bob@old.example.com",
+		"PGHI789": "first.last@corp.com",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"PABC123": "alice@corp.com",
+		"PDEF456": "bob@example.com",
+		"PGHI789": "flast@corp.com",
+	}, result)
+	assert.Empty(t, unmapped)
+}
@@ -0,0 +1,366 @@
+package msgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/gcal"
+)
+
+const (
+	// ProviderName is the name used to assign users to this provider
+	ProviderName = "msgraph"
+
+	// RuleAutomaticReplies is the rule name recorded against automatic reply (out of office) periods
+	RuleAutomaticReplies = "automatic replies"
+
+	defaultBaseURL  = "https://graph.microsoft.com/v1.0"
+	defaultTokenURL = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	graphScope      = "https://graph.microsoft.com/.default"
+
+	// Graph returns fractional seconds with 7 digits
+	graphTimeFormat = "2006-01-02T15:04:05.9999999"
+
+	// guard against paging forever
+	maxPages = 50
+)
+
+// Provider loads calendars from Microsoft 365 using the Graph API.
+// The app registration needs the Calendars.Read and MailboxSettings.Read application permissions
+type Provider struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+
+	// Rules decide which events mean the user is unavailable (default: gcal.DefaultRules()).
+	// Events shown as "Out of office" have the event type gcal.EventTypeOutOfOffice
+	Rules []*gcal.Rule
+
+	// BaseURL defaults to the Graph v1.0 endpoint
+	BaseURL string
+
+	// TokenURL defaults to the Microsoft identity platform token endpoint of the tenant
+	TokenURL string
+
+	// HTTPClient defaults to a client with a 60 second timeout
+	HTTPClient *http.Client
+
+	mutex       sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// GetCalendars implements gcal.Provider
+func (p *Provider) GetCalendars(users map[string]string, start time.Time, end time.Time) (map[string]*gcal.Calendar, error) {
+	rules := p.Rules
+	if len(rules) == 0 {
+		rules = gcal.DefaultRules()
+	}
+
+	err := gcal.CompileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	// without a token no calendar can be loaded
+	_, err = p.token()
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]*gcal.Calendar{}
+
+	// a calendar that could not be loaded is returned with Err set rather than failing the other users
+	for id, email := range users {
+		calendar := &gcal.Calendar{}
+
+		err = p.getEvents(calendar, rules, email, start, end)
+		if err != nil {
+			out[id] = &gcal.Calendar{Err: fmt.Errorf("failed to load Outlook calendar for '%s': %s", email, err)}
+			continue
+		}
+
+		err = p.getAutomaticReplies(calendar, email, start, end)
+		if err != nil {
+			out[id] = &gcal.Calendar{Err: fmt.Errorf("failed to load automatic replies for '%s': %s", email, err)}
+			continue
+		}
+
+		out[id] = calendar
+	}
+
+	return out, nil
+}
+
+// response DTOs
+type dateTimeTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type event struct {
+	ID          string            `json:"id"`
+	Subject     string            `json:"subject"`
+	BodyPreview string            `json:"bodyPreview"`
+	ShowAs      string            `json:"showAs"`
+	Sensitivity string            `json:"sensitivity"`
+	IsAllDay    bool              `json:"isAllDay"`
+	IsCancelled bool              `json:"isCancelled"`
+	WebLink     string            `json:"webLink"`
+	Start       *dateTimeTimeZone `json:"start"`
+	End         *dateTimeTimeZone `json:"end"`
+}
+
+type eventsPage struct {
+	Value    []*event `json:"value"`
+	NextLink string   `json:"@odata.nextLink"`
+}
+
+type automaticRepliesSetting struct {
+	Status                 string            `json:"status"`
+	ScheduledStartDateTime *dateTimeTimeZone `json:"scheduledStartDateTime"`
+	ScheduledEndDateTime   *dateTimeTimeZone `json:"scheduledEndDateTime"`
+}
+
+type errorResponse struct {
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// add the events (expanded by the calendar view) that match any of the rules
+func (p *Provider) getEvents(out *gcal.Calendar, rules []*gcal.Rule, email string, start time.Time, end time.Time) error {
+	params := url.Values{}
+	params.Set("startDateTime", start.AddDate(0, 0, -1).UTC().Format(time.RFC3339))
+	params.Set("endDateTime", end.AddDate(0, 0, 1).UTC().Format(time.RFC3339))
+	params.Set("$select", "id,subject,bodyPreview,showAs,sensitivity,isAllDay,isCancelled,webLink,start,end")
+	params.Set("$top", "100")
+
+	uri := p.baseURL() + "/users/" + url.PathEscape(email) + "/calendarView?" + params.Encode()
+
+	for page := 0; uri != ""; page++ {
+		if page == maxPages {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("stopped loading events for '%s' after %d pages; some events may be missing", email, maxPages))
+			break
+		}
+
+		result := &eventsPage{}
+		err := p.get(uri, result)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range result.Value {
+			if item.IsCancelled || item.Start == nil || item.End == nil {
+				continue
+			}
+
+			thisEvent, err := toEvent(item)
+			if err != nil {
+				return err
+			}
+
+			rule := gcal.MatchRule(rules, thisEvent)
+			if rule == nil {
+				continue
+			}
+
+			out.Items = append(out.Items, gcal.NewCalendarItem(thisEvent, rule))
+		}
+
+		uri = result.NextLink
+	}
+
+	return nil
+}
+
+// add the automatic replies period (if any) that overlaps the period
+func (p *Provider) getAutomaticReplies(out *gcal.Calendar, email string, start time.Time, end time.Time) error {
+	setting := &automaticRepliesSetting{}
+	err := p.get(p.baseURL()+"/users/"+url.PathEscape(email)+"/mailboxSettings/automaticRepliesSetting", setting)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(setting.Status) {
+	case "alwaysenabled":
+		// on until turned off; assume the whole period
+		out.Items = append(out.Items, &gcal.CalendarItem{Start: start, End: end, Rule: RuleAutomaticReplies, Summary: "Automatic replies"})
+
+	case "scheduled":
+		if setting.ScheduledStartDateTime == nil || setting.ScheduledEndDateTime == nil {
+			return nil
+		}
+
+		replyStart, err := parseTime(setting.ScheduledStartDateTime)
+		if err != nil {
+			return err
+		}
+
+		replyEnd, err := parseTime(setting.ScheduledEndDateTime)
+		if err != nil {
+			return err
+		}
+
+		if replyStart.Before(end) && replyEnd.After(start) {
+			out.Items = append(out.Items, &gcal.CalendarItem{Start: replyStart, End: replyEnd, Rule: RuleAutomaticReplies, Summary: "Automatic replies"})
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) get(uri string, out interface{}) error {
+	token, err := p.token()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	// return all times in UTC
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newError(resp)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// returns the access token (from the client credentials grant), reusing it until it expires
+func (p *Provider) token() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.accessToken != "" && time.Now().Before(p.expiry) {
+		return p.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("scope", graphScope)
+
+	tokenURL := p.TokenURL
+	if tokenURL == "" {
+		tokenURL = fmt.Sprintf(defaultTokenURL, url.PathEscape(p.TenantID))
+	}
+
+	resp, err := p.httpClient().PostForm(tokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	result := &struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the Microsoft token response (status %d): %s", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("failed to get a Microsoft Graph token: %s %s", result.Error, result.ErrorDescription)
+	}
+
+	p.accessToken = result.AccessToken
+	// refresh a minute early
+	p.expiry = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+
+	return p.accessToken, nil
+}
+
+func (p *Provider) baseURL() string {
+	if p.BaseURL == "" {
+		return defaultBaseURL
+	}
+	return strings.TrimSuffix(p.BaseURL, "/")
+}
+
+func (p *Provider) httpClient() *http.Client {
+	if p.HTTPClient == nil {
+		return &http.Client{Timeout: 60 * time.Second}
+	}
+	return p.HTTPClient
+}
+
+// convert the Graph event into the provider independent form
+func toEvent(item *event) (*gcal.Event, error) {
+	start, err := parseTime(item.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := parseTime(item.End)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &gcal.Event{
+		ID:           item.ID,
+		Summary:      item.Subject,
+		Description:  item.BodyPreview,
+		Transparency: "opaque",
+		Visibility:   "default",
+		HTMLLink:     item.WebLink,
+		Start:        start,
+		End:          end,
+		AllDay:       item.IsAllDay,
+	}
+
+	switch strings.ToLower(item.ShowAs) {
+	case "oof":
+		out.EventType = gcal.EventTypeOutOfOffice
+	case "free":
+		out.Transparency = "transparent"
+	}
+
+	switch strings.ToLower(item.Sensitivity) {
+	case "private", "personal":
+		out.Visibility = "private"
+	case "confidential":
+		out.Visibility = "confidential"
+	}
+
+	return out, nil
+}
+
+// times are requested in UTC but the time zone is honoured when it is a known IANA name
+func parseTime(value *dateTimeTimeZone) (time.Time, error) {
+	return time.ParseInLocation(graphTimeFormat, value.DateTime, gcal.LoadLocation(value.TimeZone, time.UTC))
+}
+
+func newError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	result := &errorResponse{}
+	if json.Unmarshal(body, result) == nil && result.Error != nil {
+		return fmt.Errorf("graph returned %d: %s: %s", resp.StatusCode, result.Error.Code, result.Error.Message)
+	}
+
+	return fmt.Errorf("graph returned %d", resp.StatusCode)
+}
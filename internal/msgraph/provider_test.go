@@ -0,0 +1,200 @@
+package msgraph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_GetCalendars(t *testing.T) {
+	// inputs
+	server := newTestGraphServer(t)
+	defer server.Close()
+
+	provider := &Provider{
+		TenantID:     "tenant",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		BaseURL:      server.URL + "/v1.0",
+		TokenURL:     server.URL + "/tenant/oauth2/v2.0/token",
+		HTTPClient:   server.Client(),
+	}
+	users := map[string]string{
+		"FOO": "foo@example.com",
+		"BAR": "bar@example.com",
+	}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	// call
+	result, resultErr := provider.GetCalendars(users, start, end)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.tokenRequests))
+
+	foo := result["FOO"]
+	assert.Equal(t, 3, len(foo.Items))
+	if len(foo.Items) == 3 {
+		// the "xoncall" event, the "out of office" event (from the 2nd page) and the scheduled automatic replies
+		assert.Equal(t, "no on-call", foo.Items[0].Rule)
+		assert.Equal(t, "out of office", foo.Items[1].Rule)
+		assert.Equal(t, time.Date(2019, 1, 7, 0, 0, 0, 0, time.UTC), foo.Items[1].Start)
+		assert.Equal(t, RuleAutomaticReplies, foo.Items[2].Rule)
+		assert.Equal(t, time.Date(2019, 1, 20, 17, 0, 0, 0, time.UTC), foo.Items[2].Start)
+	}
+
+	bar := result["BAR"]
+	assert.Equal(t, 1, len(bar.Items))
+	if len(bar.Items) == 1 {
+		assert.Equal(t, RuleAutomaticReplies, bar.Items[0].Rule)
+		assert.Equal(t, start, bar.Items[0].Start)
+		assert.Equal(t, end, bar.Items[0].End)
+	}
+}
+
+func TestProvider_GetCalendars_errors(t *testing.T) {
+	scenarios := []struct {
+		desc                string
+		inSecret            string
+		inEmail             string
+		expectedErr         string
+		expectedCalendarErr string
+	}{
+		{
+			desc:        "invalid client secret",
+			inSecret:    "wrong",
+			inEmail:     "foo@example.com",
+			expectedErr: "failed to get a Microsoft Graph token: invalid_client bad secret",
+		},
+		{
+			desc:                "unknown user",
+			inSecret:            "secret",
+			inEmail:             "nobody@example.com",
+			expectedCalendarErr: "failed to load Outlook calendar for 'nobody@example.com': graph returned 404: ErrorItemNotFound: user not found",
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			server := newTestGraphServer(t)
+			defer server.Close()
+
+			provider := &Provider{
+				ClientID:     "client",
+				ClientSecret: scenario.inSecret,
+				BaseURL:      server.URL + "/v1.0",
+				TokenURL:     server.URL + "/tenant/oauth2/v2.0/token",
+				HTTPClient:   server.Client(),
+			}
+			start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			// call
+			result, resultErr := provider.GetCalendars(map[string]string{"FOO": scenario.inEmail}, start, start.AddDate(0, 1, 0))
+
+			// validate
+			if scenario.expectedErr != "" {
+				assert.EqualError(t, resultErr, scenario.expectedErr, scenario.desc)
+				return
+			}
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.EqualError(t, result["FOO"].Err, scenario.expectedCalendarErr, scenario.desc)
+		})
+	}
+}
+
+// testGraphServer is a stand-in for the Graph API and the Microsoft identity platform
+type testGraphServer struct {
+	*httptest.Server
+
+	tokenRequests int32
+}
+
+func newTestGraphServer(t *testing.T) *testGraphServer {
+	server := &testGraphServer{}
+
+	events := map[string][][]map[string]interface{}{
+		"foo@example.com": {
+			// page 1
+			{
+				newTestEvent("1", "Workout", "busy", "2019-01-02T07:00:00.0000000", "2019-01-02T08:00:00.0000000"),
+				newTestEvent("2", "xoncall", "busy", "2019-01-10T00:00:00.0000000", "2019-01-11T00:00:00.0000000"),
+			},
+			// page 2
+			{
+				newTestEvent("3", "Vacation", "oof", "2019-01-07T00:00:00.0000000", "2019-01-09T00:00:00.0000000"),
+			},
+		},
+		"bar@example.com": {{}},
+	}
+	replies := map[string]string{
+		"foo@example.com": `{"status": "scheduled",
+			"scheduledStartDateTime": {"dateTime": "2019-01-20T17:00:00.0000000", "timeZone": "UTC"},
+			"scheduledEndDateTime": {"dateTime": "2019-01-25T09:00:00.0000000", "timeZone": "UTC"}}`,
+		"bar@example.com": `{"status": "alwaysEnabled"}`,
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Content-Type", "application/json")
+
+		if req.URL.Path == "/tenant/oauth2/v2.0/token" {
+			atomic.AddInt32(&server.tokenRequests, 1)
+			_ = req.ParseForm()
+			if req.PostForm.Get("grant_type") != "client_credentials" || req.PostForm.Get("client_secret") != "secret" {
+				resp.WriteHeader(http.StatusUnauthorized)
+				_, _ = resp.Write([]byte(`{"error": "invalid_client", "error_description": "bad secret"}`))
+				return
+			}
+			_, _ = resp.Write([]byte(`{"access_token": "graph-token", "token_type": "Bearer", "expires_in": 3600}`))
+			return
+		}
+
+		assert.Equal(t, "Bearer graph-token", req.Header.Get("Authorization"))
+
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/v1.0/users/"), "/")
+		email := parts[0]
+		userEvents, found := events[email]
+		if !found {
+			resp.WriteHeader(http.StatusNotFound)
+			_, _ = resp.Write([]byte(`{"error": {"code": "ErrorItemNotFound", "message": "user not found"}}`))
+			return
+		}
+
+		if parts[len(parts)-1] == "automaticRepliesSetting" {
+			_, _ = resp.Write([]byte(replies[email]))
+			return
+		}
+
+		// calendarView; the "page" parameter is only used by the next link
+		page := 0
+		if req.URL.Query().Get("page") == "2" {
+			page = 1
+		}
+
+		result := map[string]interface{}{"value": userEvents[page]}
+		if page+1 < len(userEvents) {
+			result["@odata.nextLink"] = "http://" + req.Host + req.URL.Path + "?page=2"
+		}
+		_ = json.NewEncoder(resp).Encode(result)
+	}))
+
+	return server
+}
+
+func newTestEvent(id string, subject string, showAs string, start string, end string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          id,
+		"subject":     subject,
+		"showAs":      showAs,
+		"sensitivity": "normal",
+		"start":       map[string]string{"dateTime": start, "timeZone": "UTC"},
+		"end":         map[string]string{"dateTime": end, "timeZone": "UTC"},
+	}
+}
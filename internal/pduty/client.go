@@ -0,0 +1,227 @@
+package pduty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Option configures the PD API client
+type Option func(*client)
+
+// WithAPIKey sets the API key used to authenticate all calls
+func WithAPIKey(apiKey string) Option {
+	return func(c *client) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithBaseURL replaces the PD API URL (e.g. with a fake server)
+func WithBaseURL(baseURL string) Option {
+	return func(c *client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport replaces the transport of the HTTP client
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *client) {
+		c.transport = transport
+	}
+}
+
+// WithConcurrency sets the maximum number of concurrent calls made when loading many items (e.g. users)
+func WithConcurrency(concurrency int) Option {
+	return func(c *client) {
+		c.concurrency = concurrency
+	}
+}
+
+// WithUserCache enables caching of user details (see NewUserCache)
+func WithUserCache(userCache *UserCache) Option {
+	return func(c *client) {
+		c.userCache = userCache
+	}
+}
+
+// WithRetries sets the number of times that rate limited (or failed) calls are retried and the initial delay between
+// retries (it doubles after each retry)
+func WithRetries(maxRetries int, baseDelay time.Duration) Option {
+	return func(c *client) {
+		c.maxRetries = maxRetries
+		c.baseDelay = baseDelay
+	}
+}
+
+var (
+	defaultClientOnce sync.Once
+	defaultClientInst *client
+)
+
+// returns the client used by the zero value of the APIs
+func defaultClient() *client {
+	defaultClientOnce.Do(func() {
+		defaultClientInst = newClient(nil)
+	})
+
+	return defaultClientInst
+}
+
+// client contains the config and functions shared by all the APIs
+type client struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+	transport  http.RoundTripper
+
+	maxRetries int
+	baseDelay  time.Duration
+
+	concurrency int
+	userCache   *UserCache
+}
+
+func newClient(options []Option) *client {
+	out := &client{
+		baseURL:     apiBaseURL,
+		maxRetries:  defaultMaxRetries,
+		baseDelay:   defaultBaseDelay,
+		concurrency: 5,
+	}
+
+	for _, option := range options {
+		option(out)
+	}
+
+	if out.concurrency < 1 {
+		out.concurrency = 1
+	}
+
+	if out.httpClient == nil {
+		out.httpClient = &http.Client{
+			// includes the time spent waiting between retries
+			Timeout: 60 * time.Second,
+		}
+	}
+
+	transport := out.httpClient.Transport
+	if out.transport != nil {
+		transport = out.transport
+	}
+
+	// copy so that the supplied client is not modified
+	httpClient := *out.httpClient
+	httpClient.Transport = newRetryTransport(transport, out.maxRetries, out.baseDelay)
+	out.httpClient = &httpClient
+
+	return out
+}
+
+// build a request to the supplied path; body (when not nil) is sent as JSON
+func (c *client) newRequest(method string, path string, params url.Values, body interface{}) (*http.Request, error) {
+	var reqBody io.Reader
+
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		reqBody = bytes.NewReader(payload)
+	}
+
+	uri := c.baseURL + path
+	if len(params) > 0 {
+		uri += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(method, uri, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Token token="+c.apiKey)
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// send the request and decode the JSON response into out
+func (c *client) do(req *http.Request, out interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return newAPIError(resp)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+
+	err = decoder.Decode(out)
+	if err != nil {
+		return fmt.Errorf("failed to decode response to JSON with err: %s", err)
+	}
+
+	return nil
+}
+
+// call fn for each user using a bounded pool of workers; returns the first error
+func (c *client) forEachUser(users []*User, fn func(user *User) error) error {
+	return c.forEach(len(users), func(index int) error {
+		return fn(users[index])
+	})
+}
+
+// call fn with the index of each of the count items using a bounded pool of workers; returns the first error
+func (c *client) forEach(count int, fn func(index int) error) error {
+	var firstErr error
+	mutex := &sync.Mutex{}
+
+	queue := make(chan int)
+	wg := &sync.WaitGroup{}
+
+	for x := 0; x < c.concurrency; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range queue {
+				err := fn(index)
+
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for index := 0; index < count; index++ {
+		queue <- index
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,51 @@
+package pduty
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_Options(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	transport := &countingTransport{}
+	httpClient := &http.Client{Timeout: time.Second}
+
+	// call
+	api := NewScheduleAPI(
+		WithAPIKey(testAPIKey),
+		WithBaseURL(server.URL),
+		WithHTTPClient(httpClient),
+		WithTransport(transport),
+	)
+	result, resultErr := api.GetSchedule("SCHED", testStart, testStart.Add(8*time.Hour))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.NotNil(t, result)
+	assert.Equal(t, 1, transport.requests)
+	assert.Nil(t, httpClient.Transport, "supplied client should not be modified")
+}
+
+func TestClient_zeroValueAPI(t *testing.T) {
+	// call
+	api := &ScheduleAPI{}
+	req, resultErr := api.buildRequest("SCHED", testStart, testStart.Add(8*time.Hour))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, apiBaseURL+"/schedules/SCHED", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+}
@@ -2,10 +2,24 @@ package pduty
 
 const (
 	// this is the base URL for all API calls
-	apiBaseURL = "https://api.pagerduty.com/"
+	apiBaseURL = "https://api.pagerduty.com"
 )
 
 type apiResponse struct {
 	ScheduleOuter *scheduleOuter `json:"schedule"`
-	UserOuter     *userOuter     `json:"user"`
+	UserOuter     *UserDetails   `json:"user"`
+	Override      *Override      `json:"override"`
+	Overrides     []*Override    `json:"overrides"`
+
+	ContactMethods    []*ContactMethod    `json:"contact_methods"`
+	NotificationRules []*NotificationRule `json:"notification_rules"`
+
+	Incidents  []*Incident
+	LogEntries []*logEntry `json:"log_entries"`
+
+	EscalationPolicy   *escalationPolicyOuter   `json:"escalation_policy"`
+	EscalationPolicies []*escalationPolicyOuter `json:"escalation_policies"`
+
+	// set by list APIs when there are more pages
+	More bool
 }
@@ -0,0 +1,69 @@
+package pduty
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Override is a request/response DTO
+type Override struct {
+	ID    string
+	Start time.Time
+	End   time.Time
+	User  *User
+}
+
+func (o *Override) String() string {
+	return fmt.Sprintf("User: %s - Start: %s - End: %s", o.User.Name, o.Start.Format(time.RFC3339), o.End.Format(time.RFC3339))
+}
+
+// CreateOverride will add the supplied override to the schedule and return the override as created by PD
+func (s *ScheduleAPI) CreateOverride(scheduleID string, override *Override) (*Override, error) {
+	req, err := s.buildOverrideRequest(scheduleID, override)
+	if err != nil {
+		return nil, err
+	}
+
+	apiResp := &apiResponse{}
+	err = s.getClient().do(req, apiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiResp.Override == nil {
+		return nil, fmt.Errorf("override missing from response")
+	}
+
+	return apiResp.Override, nil
+}
+
+func (s *ScheduleAPI) buildOverrideRequest(scheduleID string, override *Override) (*http.Request, error) {
+	body := &overrideRequest{
+		Override: &overrideBody{
+			Start: override.Start.Format(time.RFC3339),
+			End:   override.End.Format(time.RFC3339),
+			User: &userReference{
+				ID:   override.User.ID,
+				Type: "user_reference",
+			},
+		},
+	}
+
+	return s.getClient().newRequest("POST", "/schedules/"+scheduleID+"/overrides", nil, body)
+}
+
+type overrideRequest struct {
+	Override *overrideBody `json:"override"`
+}
+
+type overrideBody struct {
+	Start string         `json:"start"`
+	End   string         `json:"end"`
+	User  *userReference `json:"user"`
+}
+
+type userReference struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
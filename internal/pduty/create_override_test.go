@@ -0,0 +1,82 @@
+package pduty
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleAPI_CreateOverride(t *testing.T) {
+	scenarios := []struct {
+		desc         string
+		inStatusCode int
+		inResponse   string
+		expectedID   string
+		expectErr    bool
+	}{
+		{
+			desc:         "happy path",
+			inStatusCode: http.StatusCreated,
+			inResponse:   `{"override":{"id":"PQ47DCP","start":"2019-01-02T00:00:00Z","end":"2019-01-02T08:00:00Z","user":{"id":"BAR","summary":"Bar"}}}`,
+			expectedID:   "PQ47DCP",
+			expectErr:    false,
+		},
+		{
+			desc:         "sad path - rejected by PD",
+			inStatusCode: http.StatusBadRequest,
+			inResponse:   `{"error":{"message":"Invalid Input Provided"}}`,
+			expectErr:    true,
+		},
+		{
+			desc:         "sad path - missing override in response",
+			inStatusCode: http.StatusCreated,
+			inResponse:   `{}`,
+			expectErr:    true,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			var receivedPath string
+			receivedBody := &overrideRequest{}
+
+			server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				receivedPath = req.Method + " " + req.URL.Path
+				assert.Equal(t, "Token token=my-key", req.Header.Get("Authorization"))
+				assert.Nil(t, json.NewDecoder(req.Body).Decode(receivedBody))
+
+				resp.WriteHeader(scenario.inStatusCode)
+				_, _ = resp.Write([]byte(scenario.inResponse))
+			}))
+			defer server.Close()
+
+			override := &Override{
+				Start: time.Date(2019, 01, 02, 0, 0, 0, 0, time.UTC),
+				End:   time.Date(2019, 01, 02, 8, 0, 0, 0, time.UTC),
+				User: &User{
+					ID: "BAR",
+				},
+			}
+
+			// call
+			api := NewScheduleAPI(WithAPIKey("my-key"), WithBaseURL(server.URL))
+			result, resultErr := api.CreateOverride("SCHED", override)
+
+			// validate
+			assert.Equal(t, scenario.expectErr, resultErr != nil, scenario.desc)
+			assert.Equal(t, "POST /schedules/SCHED/overrides", receivedPath, scenario.desc)
+			assert.Equal(t, "BAR", receivedBody.Override.User.ID, scenario.desc)
+			assert.Equal(t, "user_reference", receivedBody.Override.User.Type, scenario.desc)
+			assert.Equal(t, "2019-01-02T00:00:00Z", receivedBody.Override.Start, scenario.desc)
+			assert.Equal(t, "2019-01-02T08:00:00Z", receivedBody.Override.End, scenario.desc)
+			if !scenario.expectErr {
+				assert.Equal(t, scenario.expectedID, result.ID, scenario.desc)
+			}
+		})
+	}
+}
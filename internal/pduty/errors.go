@@ -0,0 +1,101 @@
+package pduty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrUnauthorized is returned when PD rejects the API key (HTTP 401 or 403)
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrNotFound is returned when the requested item does not exist (HTTP 404)
+	ErrNotFound = errors.New("not found")
+
+	// ErrRateLimited is returned when PD is still rate limiting after all the retries (HTTP 429)
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrServerError is returned when PD is still failing after all the retries (HTTP 5xx)
+	ErrServerError = errors.New("server error")
+)
+
+// APIError is returned when PD responds with an unexpected status code.
+// Use errors.Is() with the Err* variables above to determine the type of error
+type APIError struct {
+	StatusCode int
+	Message    string
+
+	kind error
+}
+
+func (e *APIError) Error() string {
+	out := fmt.Sprintf("unexpected status code %d", e.StatusCode)
+	if e.kind != nil {
+		out += " (" + e.kind.Error() + ")"
+	}
+	if e.Message != "" {
+		out += ": " + e.Message
+	}
+	return out
+}
+
+// Unwrap returns the type of error (if known)
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// build an error from the (failed) response
+func newAPIError(resp *http.Response) *APIError {
+	out := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    readErrorMessage(resp.Body),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		out.kind = ErrUnauthorized
+
+	case resp.StatusCode == http.StatusNotFound:
+		out.kind = ErrNotFound
+
+	case resp.StatusCode == http.StatusTooManyRequests:
+		out.kind = ErrRateLimited
+
+	case resp.StatusCode >= http.StatusInternalServerError:
+		out.kind = ErrServerError
+	}
+
+	return out
+}
+
+// PD errors look like: {"error": {"message": "Invalid Input Provided", "code": 2001, "errors": ["..."]}}
+func readErrorMessage(body io.Reader) string {
+	payload, err := ioutil.ReadAll(io.LimitReader(body, 64*1024))
+	if err != nil {
+		return ""
+	}
+
+	errResp := &struct {
+		Error *struct {
+			Message string
+			Errors  []string
+		}
+	}{}
+
+	err = json.Unmarshal(payload, errResp)
+	if err != nil || errResp.Error == nil {
+		return ""
+	}
+
+	out := errResp.Error.Message
+	if len(errResp.Error.Errors) > 0 {
+		out += " (" + strings.Join(errResp.Error.Errors, ", ") + ")"
+	}
+
+	return out
+}
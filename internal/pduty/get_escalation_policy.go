@@ -0,0 +1,131 @@
+package pduty
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// EscalationPolicy is a response DTO
+type EscalationPolicy struct {
+	ID   string
+	Name string
+
+	// ScheduleIDs are the schedules referenced by the rules of this policy (in the order they are escalated)
+	ScheduleIDs []string
+}
+
+// EscalationPolicyAPI contains the functions to call the escalation policy APIs.
+// The zero value uses the default options (i.e. without an API key); use NewEscalationPolicyAPI to configure it
+type EscalationPolicyAPI struct {
+	client *client
+}
+
+// NewEscalationPolicyAPI returns an EscalationPolicyAPI configured with the supplied options
+func NewEscalationPolicyAPI(options ...Option) *EscalationPolicyAPI {
+	return &EscalationPolicyAPI{
+		client: newClient(options),
+	}
+}
+
+func (e *EscalationPolicyAPI) getClient() *client {
+	if e.client == nil {
+		return defaultClient()
+	}
+
+	return e.client
+}
+
+// GetEscalationPolicy will return the escalation policy for the supplied id
+func (e *EscalationPolicyAPI) GetEscalationPolicy(policyID string) (*EscalationPolicy, error) {
+	req, err := e.getClient().newRequest("GET", "/escalation_policies/"+policyID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	apiResp := &apiResponse{}
+	err = e.getClient().do(req, apiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiResp.EscalationPolicy == nil {
+		return nil, fmt.Errorf("escalation policy missing from response")
+	}
+
+	return apiResp.EscalationPolicy.toEscalationPolicy(), nil
+}
+
+// GetTeamEscalationPolicies will return all the escalation policies that belong to the supplied team
+func (e *EscalationPolicyAPI) GetTeamEscalationPolicies(teamID string) ([]*EscalationPolicy, error) {
+	var out []*EscalationPolicy
+
+	for offset := 0; ; {
+		req, err := e.buildListRequest(teamID, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		apiResp := &apiResponse{}
+		err = e.getClient().do(req, apiResp)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, policy := range apiResp.EscalationPolicies {
+			out = append(out, policy.toEscalationPolicy())
+		}
+
+		if !apiResp.More || len(apiResp.EscalationPolicies) == 0 {
+			return out, nil
+		}
+
+		offset += len(apiResp.EscalationPolicies)
+	}
+}
+
+func (e *EscalationPolicyAPI) buildListRequest(teamID string, offset int) (*http.Request, error) {
+	params := url.Values{}
+	params.Set("team_ids[]", teamID)
+	params.Set("limit", "100")
+	params.Set("offset", strconv.Itoa(offset))
+
+	return e.getClient().newRequest("GET", "/escalation_policies", params, nil)
+}
+
+type escalationPolicyOuter struct {
+	ID              string
+	Name            string
+	EscalationRules []*struct {
+		Targets []*struct {
+			ID   string
+			Type string
+		}
+	} `json:"escalation_rules"`
+}
+
+func (e *escalationPolicyOuter) toEscalationPolicy() *EscalationPolicy {
+	out := &EscalationPolicy{
+		ID:   e.ID,
+		Name: e.Name,
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range e.EscalationRules {
+		for _, target := range rule.Targets {
+			if target.Type != "schedule_reference" && target.Type != "schedule" {
+				continue
+			}
+
+			if seen[target.ID] {
+				continue
+			}
+			seen[target.ID] = true
+
+			out.ScheduleIDs = append(out.ScheduleIDs, target.ID)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,86 @@
+package pduty
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty/pdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscalationPolicyAPI_GetEscalationPolicy(t *testing.T) {
+	server := pdtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	server.AddEscalationPolicy(&pdtest.EscalationPolicy{
+		ID:          "POLICY",
+		Name:        "Payments",
+		ScheduleIDs: []string{"PRIMARY", "SECONDARY", "PRIMARY"},
+	})
+
+	scenarios := []struct {
+		desc        string
+		inPolicyID  string
+		expectedIDs []string
+		expectErr   bool
+	}{
+		{
+			desc:        "happy path",
+			inPolicyID:  "POLICY",
+			expectedIDs: []string{"PRIMARY", "SECONDARY"},
+			expectErr:   false,
+		},
+		{
+			desc:       "sad path - unknown policy",
+			inPolicyID: "UNKNOWN",
+			expectErr:  true,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			api := NewEscalationPolicyAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+			result, resultErr := api.GetEscalationPolicy(scenario.inPolicyID)
+
+			// validate
+			assert.Equal(t, scenario.expectErr, resultErr != nil, scenario.desc)
+			if !scenario.expectErr {
+				assert.Equal(t, "Payments", result.Name, scenario.desc)
+				assert.Equal(t, scenario.expectedIDs, result.ScheduleIDs, scenario.desc)
+			}
+		})
+	}
+}
+
+func TestEscalationPolicyAPI_GetTeamEscalationPolicies(t *testing.T) {
+	server := pdtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	// more than 1 page of policies for the team
+	for x := 0; x < 150; x++ {
+		server.AddEscalationPolicy(&pdtest.EscalationPolicy{
+			ID:          fmt.Sprintf("POLICY%d", x),
+			TeamIDs:     []string{"TEAM"},
+			ScheduleIDs: []string{fmt.Sprintf("SCHED%d", x)},
+		})
+	}
+	server.AddEscalationPolicy(&pdtest.EscalationPolicy{
+		ID:          "OTHER",
+		TeamIDs:     []string{"OTHER_TEAM"},
+		ScheduleIDs: []string{"OTHER_SCHED"},
+	})
+
+	// call
+	api := NewEscalationPolicyAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+	result, resultErr := api.GetTeamEscalationPolicies("TEAM")
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 150, len(result))
+	assert.Equal(t, 2, server.RequestCount("GET", "/escalation_policies"))
+	for _, policy := range result {
+		assert.NotEqual(t, "OTHER", policy.ID)
+	}
+}
@@ -0,0 +1,191 @@
+package pduty
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Incident is a response DTO
+type Incident struct {
+	ID                 string
+	Title              string
+	Status             string
+	Urgency            string
+	CreatedAt          time.Time `json:"created_at"`
+	LastStatusChangeAt time.Time `json:"last_status_change_at"`
+	EscalationPolicy   *struct {
+		ID string
+	} `json:"escalation_policy"`
+	Acknowledgements []*struct {
+		At time.Time
+	}
+
+	// FirstAcknowledgedAt is loaded from the log entries of resolved incidents (PD clears their acknowledgements)
+	FirstAcknowledgedAt time.Time `json:"-"`
+}
+
+// AcknowledgedAt returns the time of the first acknowledgement (zero if the incident was not acknowledged)
+func (i *Incident) AcknowledgedAt() time.Time {
+	if !i.FirstAcknowledgedAt.IsZero() {
+		return i.FirstAcknowledgedAt
+	}
+
+	var out time.Time
+
+	for _, ack := range i.Acknowledgements {
+		if out.IsZero() || ack.At.Before(out) {
+			out = ack.At
+		}
+	}
+
+	return out
+}
+
+// ResolvedAt returns the time the incident was resolved (zero if it is not resolved)
+func (i *Incident) ResolvedAt() time.Time {
+	if i.Status != "resolved" {
+		return time.Time{}
+	}
+
+	return i.LastStatusChangeAt
+}
+
+type logEntry struct {
+	Type      string
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IncidentAPI contains the functions to call the incident APIs.
+// The zero value uses the default options (i.e. without an API key); use NewIncidentAPI to configure it
+type IncidentAPI struct {
+	client *client
+}
+
+// NewIncidentAPI returns an IncidentAPI configured with the supplied options
+func NewIncidentAPI(options ...Option) *IncidentAPI {
+	return &IncidentAPI{
+		client: newClient(options),
+	}
+}
+
+func (i *IncidentAPI) getClient() *client {
+	if i.client == nil {
+		return defaultClient()
+	}
+
+	return i.client
+}
+
+// GetIncidents will return the incidents created during the period for any of the supplied escalation policies.
+// The acknowledge time of resolved incidents is loaded from their log entries
+func (i *IncidentAPI) GetIncidents(escalationPolicyIDs []string, start time.Time, end time.Time) ([]*Incident, error) {
+	incidents, err := i.listIncidents(escalationPolicyIDs, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved []*Incident
+	for _, incident := range incidents {
+		if incident.Status == "resolved" {
+			resolved = append(resolved, incident)
+		}
+	}
+
+	err = i.getClient().forEach(len(resolved), func(index int) error {
+		acknowledgedAt, err := i.getAcknowledgedAt(resolved[index].ID)
+		if err != nil {
+			return err
+		}
+
+		resolved[index].FirstAcknowledgedAt = acknowledgedAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return incidents, nil
+}
+
+func (i *IncidentAPI) listIncidents(escalationPolicyIDs []string, start time.Time, end time.Time) ([]*Incident, error) {
+	wanted := map[string]bool{}
+	for _, id := range escalationPolicyIDs {
+		wanted[id] = true
+	}
+
+	var out []*Incident
+
+	for offset := 0; ; {
+		req, err := i.buildRequest(start, end, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		apiResp := &apiResponse{}
+		err = i.getClient().do(req, apiResp)
+		if err != nil {
+			return nil, err
+		}
+
+		// the API cannot filter by escalation policy
+		for _, incident := range apiResp.Incidents {
+			if incident.EscalationPolicy != nil && wanted[incident.EscalationPolicy.ID] {
+				out = append(out, incident)
+			}
+		}
+
+		if !apiResp.More || len(apiResp.Incidents) == 0 {
+			return out, nil
+		}
+
+		offset += len(apiResp.Incidents)
+	}
+}
+
+// returns the time of the first acknowledge log entry (zero if the incident was not acknowledged)
+func (i *IncidentAPI) getAcknowledgedAt(incidentID string) (time.Time, error) {
+	var out time.Time
+
+	for offset := 0; ; {
+		params := url.Values{}
+		params.Set("time_zone", "UTC")
+		params.Set("limit", "100")
+		params.Set("offset", strconv.Itoa(offset))
+
+		req, err := i.getClient().newRequest("GET", "/incidents/"+incidentID+"/log_entries", params, nil)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		apiResp := &apiResponse{}
+		err = i.getClient().do(req, apiResp)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		for _, entry := range apiResp.LogEntries {
+			if entry.Type == "acknowledge_log_entry" && (out.IsZero() || entry.CreatedAt.Before(out)) {
+				out = entry.CreatedAt
+			}
+		}
+
+		if !apiResp.More || len(apiResp.LogEntries) == 0 {
+			return out, nil
+		}
+
+		offset += len(apiResp.LogEntries)
+	}
+}
+
+func (i *IncidentAPI) buildRequest(start time.Time, end time.Time, offset int) (*http.Request, error) {
+	params := url.Values{}
+	params.Set("time_zone", "UTC")
+	params.Set("since", start.Format(time.RFC3339))
+	params.Set("until", end.Format(time.RFC3339))
+	params.Set("limit", "100")
+	params.Set("offset", strconv.Itoa(offset))
+
+	return i.getClient().newRequest("GET", "/incidents", params, nil)
+}
@@ -0,0 +1,82 @@
+package pduty
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty/pdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncidentAPI_GetIncidents(t *testing.T) {
+	server := pdtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	// more than 1 page of incidents for the policy
+	for x := 0; x < 120; x++ {
+		server.AddIncident(&pdtest.Incident{
+			ID:                 fmt.Sprintf("INCIDENT%d", x),
+			EscalationPolicyID: "POLICY",
+			CreatedAt:          testStart.Add(time.Duration(x) * time.Minute),
+		})
+	}
+
+	// different policy
+	server.AddIncident(&pdtest.Incident{
+		ID:                 "OTHER",
+		EscalationPolicyID: "OTHER_POLICY",
+		CreatedAt:          testStart,
+	})
+
+	// outside the period
+	server.AddIncident(&pdtest.Incident{
+		ID:                 "OLD",
+		EscalationPolicyID: "POLICY",
+		CreatedAt:          testStart.Add(-time.Hour),
+	})
+
+	// acknowledged and resolved
+	server.AddIncident(&pdtest.Incident{
+		ID:                 "RESOLVED",
+		EscalationPolicyID: "POLICY",
+		CreatedAt:          testStart,
+		AcknowledgedAt:     testStart.Add(5 * time.Minute),
+		ResolvedAt:         testStart.Add(65 * time.Minute),
+	})
+
+	// acknowledged only
+	server.AddIncident(&pdtest.Incident{
+		ID:                 "ACKNOWLEDGED",
+		EscalationPolicyID: "POLICY",
+		CreatedAt:          testStart.Add(time.Hour),
+		AcknowledgedAt:     testStart.Add(70 * time.Minute),
+	})
+
+	// call
+	api := NewIncidentAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+	result, resultErr := api.GetIncidents([]string{"POLICY"}, testStart, testStart.Add(24*time.Hour))
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 122, len(result))
+	assert.Equal(t, 2, server.RequestCount("GET", "/incidents"))
+
+	// only resolved incidents need their log entries
+	assert.Equal(t, 1, server.RequestCount("GET", "/incidents/RESOLVED/log_entries"))
+	assert.Equal(t, 0, server.RequestCount("GET", "/incidents/ACKNOWLEDGED/log_entries"))
+
+	resolved := result[len(result)-2]
+	assert.Equal(t, "RESOLVED", resolved.ID)
+	assert.Empty(t, resolved.Acknowledgements)
+	assert.True(t, resolved.AcknowledgedAt().Equal(testStart.Add(5*time.Minute)))
+	assert.True(t, resolved.ResolvedAt().Equal(testStart.Add(65*time.Minute)))
+
+	acknowledged := result[len(result)-1]
+	assert.Equal(t, "ACKNOWLEDGED", acknowledged.ID)
+	assert.True(t, acknowledged.AcknowledgedAt().Equal(testStart.Add(70*time.Minute)))
+	assert.True(t, acknowledged.ResolvedAt().IsZero())
+
+	assert.True(t, result[0].AcknowledgedAt().IsZero())
+	assert.True(t, result[0].ResolvedAt().IsZero())
+}
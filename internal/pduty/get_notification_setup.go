@@ -0,0 +1,124 @@
+package pduty
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContactMethod is a response DTO
+type ContactMethod struct {
+	ID      string
+	Type    string
+	Label   string
+	Address string
+}
+
+// Kind returns the type without the "_reference" suffix used when the contact method is embedded in other responses
+// (e.g. "phone_contact_method")
+func (c *ContactMethod) Kind() string {
+	return strings.TrimSuffix(c.Type, "_reference")
+}
+
+// NotificationRule is a response DTO
+type NotificationRule struct {
+	ID                  string
+	Urgency             string
+	StartDelayInMinutes int            `json:"start_delay_in_minutes"`
+	ContactMethod       *ContactMethod `json:"contact_method"`
+}
+
+// NotificationSetup is a response DTO; it contains how PD can reach a user
+type NotificationSetup struct {
+	UserID            string
+	ContactMethods    []*ContactMethod
+	NotificationRules []*NotificationRule
+}
+
+// GetNotificationSetups will return the contact methods and notification rules of every user in the entries
+// (mapped by PD user id).
+// Users whose setup could not be loaded are left out; they are named in the returned error (which wraps the first cause)
+func (u *UserAPI) GetNotificationSetups(entries []*ScheduleEntry) (map[string]*NotificationSetup, error) {
+	var users []*User
+	seen := map[string]bool{}
+
+	for _, entry := range entries {
+		if seen[entry.User.ID] {
+			continue
+		}
+		seen[entry.User.ID] = true
+
+		users = append(users, entry.User)
+	}
+
+	out := map[string]*NotificationSetup{}
+	mutex := &sync.Mutex{}
+
+	var failed []string
+	var firstErr error
+
+	_ = u.getClient().forEachUser(users, func(user *User) error {
+		result, err := u.getNotificationSetup(user.ID)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err != nil {
+			failed = append(failed, user.ID)
+			if firstErr == nil {
+				firstErr = err
+			}
+			return nil
+		}
+
+		out[user.ID] = result
+		return nil
+	})
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return out, fmt.Errorf("failed to load the notification setup for %s: %w", strings.Join(failed, ", "), firstErr)
+	}
+
+	return out, nil
+}
+
+func (u *UserAPI) getNotificationSetup(userID string) (*NotificationSetup, error) {
+	req, err := u.getClient().newRequest("GET", "/users/"+userID+"/contact_methods", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	contactMethods := &apiResponse{}
+	err = u.getClient().do(req, contactMethods)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err = u.buildNotificationRulesRequest(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notificationRules := &apiResponse{}
+	err = u.getClient().do(req, notificationRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotificationSetup{
+		UserID:            userID,
+		ContactMethods:    contactMethods.ContactMethods,
+		NotificationRules: notificationRules.NotificationRules,
+	}, nil
+}
+
+func (u *UserAPI) buildNotificationRulesRequest(userID string) (*http.Request, error) {
+	params := url.Values{}
+	params.Set("urgency", "high")
+
+	return u.getClient().newRequest("GET", "/users/"+userID+"/notification_rules", params, nil)
+}
@@ -0,0 +1,80 @@
+package pduty
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty/pdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAPI_GetNotificationSetups(t *testing.T) {
+	server := pdtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	server.AddUser(&pdtest.User{
+		ID: "FOO",
+		ContactMethods: []*pdtest.ContactMethod{
+			{ID: "PHONE", Type: "phone_contact_method", Address: "5555555555"},
+			{ID: "EMAIL", Type: "email_contact_method", Address: "foo@example.com"},
+		},
+		NotificationRules: []*pdtest.NotificationRule{
+			{ID: "HIGH", Urgency: "high", ContactMethodID: "PHONE"},
+			{ID: "LOW", Urgency: "low", ContactMethodID: "EMAIL"},
+		},
+	})
+	server.AddUser(&pdtest.User{ID: "BAR"})
+
+	entries := []*ScheduleEntry{
+		{User: &User{ID: "FOO"}},
+		{User: &User{ID: "BAR"}},
+		{User: &User{ID: "FOO"}},
+	}
+
+	// call
+	api := NewUserAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+	result, resultErr := api.GetNotificationSetups(entries)
+
+	// validate
+	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, 1, server.RequestCount("GET", "/users/FOO/contact_methods"))
+
+	foo := result["FOO"]
+	assert.Equal(t, 2, len(foo.ContactMethods))
+	if assert.Equal(t, 1, len(foo.NotificationRules)) {
+		assert.Equal(t, "high", foo.NotificationRules[0].Urgency)
+		assert.Equal(t, "phone_contact_method", foo.NotificationRules[0].ContactMethod.Kind())
+	}
+
+	assert.Equal(t, 0, len(result["BAR"].ContactMethods))
+	assert.Equal(t, 0, len(result["BAR"].NotificationRules))
+}
+
+func TestUserAPI_GetNotificationSetups_partialFailure(t *testing.T) {
+	server := pdtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	server.AddUser(&pdtest.User{
+		ID:             "FOO",
+		ContactMethods: []*pdtest.ContactMethod{{ID: "PHONE", Type: "phone_contact_method"}},
+	})
+
+	entries := []*ScheduleEntry{
+		{User: &User{ID: "FOO"}},
+		{User: &User{ID: "MISSING"}},
+	}
+
+	// call
+	api := NewUserAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+	result, resultErr := api.GetNotificationSetups(entries)
+
+	// validate
+	assert.NotNil(t, resultErr)
+	assert.Contains(t, resultErr.Error(), "failed to load the notification setup for MISSING")
+	assert.True(t, errors.Is(resultErr, ErrNotFound))
+
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, 1, len(result["FOO"].ContactMethods))
+	assert.Nil(t, result["MISSING"])
+}
@@ -0,0 +1,69 @@
+package pduty
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// GetOverrides will return the overrides of the supplied schedule that fall within the period
+func (s *ScheduleAPI) GetOverrides(scheduleID string, start time.Time, end time.Time) ([]*Override, error) {
+	req, err := s.buildOverridesRequest(scheduleID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	apiResp := &apiResponse{}
+	err = s.getClient().do(req, apiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiResp.Overrides, nil
+}
+
+func (s *ScheduleAPI) buildOverridesRequest(scheduleID string, start time.Time, end time.Time) (*http.Request, error) {
+	params := url.Values{}
+	params.Set("time_zone", "UTC")
+	params.Set("since", start.Format(time.RFC3339))
+	params.Set("until", end.Format(time.RFC3339))
+
+	return s.getClient().newRequest("GET", "/schedules/"+scheduleID+"/overrides", params, nil)
+}
+
+// MarkOverrides will flag the schedule entries that are covered by one of the overrides (for the same user)
+func MarkOverrides(schedule *Schedule, overrides []*Override) {
+	for _, entry := range schedule.Entries {
+		for _, override := range overrides {
+			if override.User == nil || entry.User == nil || override.User.ID != entry.User.ID {
+				continue
+			}
+
+			if override.Start.After(entry.Start) || override.End.Before(entry.End) {
+				// override does not cover the entry
+				continue
+			}
+
+			entry.IsOverride = true
+			entry.OverrideID = override.ID
+			break
+		}
+	}
+}
+
+// OriginalUser returns the user that the schedule layer assigned to the entry's slot
+// (i.e. the user that was replaced by an override); nil when it cannot be determined
+func OriginalUser(schedule *Schedule, entry *ScheduleEntry) *User {
+	for _, layer := range schedule.Layers {
+		if layer.ID != entry.LayerID {
+			continue
+		}
+
+		layerEntry := findOverlapping(layer.Entries, entry, false)
+		if layerEntry != nil {
+			return layerEntry.User
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package pduty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleAPI_GetOverrides(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	api := NewScheduleAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+
+	// BAR takes FOO's first shift
+	_, err := api.CreateOverride("SCHED", &Override{Start: testStart, End: testStart.Add(8 * time.Hour), User: &User{ID: "BAR"}})
+	assert.Nil(t, err)
+
+	// outside the period
+	_, err = api.CreateOverride("SCHED", &Override{Start: testStart.Add(48 * time.Hour), End: testStart.Add(56 * time.Hour), User: &User{ID: "BAR"}})
+	assert.Nil(t, err)
+
+	// call
+	result, resultErr := api.GetOverrides("SCHED", testStart, testStart.Add(24*time.Hour))
+
+	// validate
+	assert.Nil(t, resultErr)
+	if assert.Equal(t, 1, len(result)) {
+		assert.Equal(t, "BAR", result[0].User.ID)
+		assert.True(t, result[0].Start.Equal(testStart))
+	}
+}
+
+func TestMarkOverrides(t *testing.T) {
+	covered := &ScheduleEntry{User: &User{ID: "BAR"}, Start: testStart, End: testStart.Add(4 * time.Hour)}
+	differentUser := &ScheduleEntry{User: &User{ID: "FOO"}, Start: testStart.Add(4 * time.Hour), End: testStart.Add(8 * time.Hour)}
+	notCovered := &ScheduleEntry{User: &User{ID: "BAR"}, Start: testStart.Add(8 * time.Hour), End: testStart.Add(16 * time.Hour)}
+
+	schedule := &Schedule{
+		Entries: []*ScheduleEntry{covered, differentUser, notCovered},
+	}
+	overrides := []*Override{
+		{ID: "OVERRIDE", User: &User{ID: "BAR"}, Start: testStart, End: testStart.Add(8 * time.Hour)},
+	}
+
+	// call
+	MarkOverrides(schedule, overrides)
+
+	// validate
+	assert.True(t, covered.IsOverride)
+	assert.Equal(t, "OVERRIDE", covered.OverrideID)
+	assert.False(t, differentUser.IsOverride)
+	assert.False(t, notCovered.IsOverride)
+}
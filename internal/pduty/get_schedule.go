@@ -1,7 +1,6 @@
 package pduty
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -19,72 +18,202 @@ type ScheduleEntry struct {
 	Start time.Time
 	End   time.Time
 	User  *User
+
+	// the layer (slot) this entry was rendered from; empty when it could not be determined
+	LayerID   string `json:"-"`
+	LayerName string `json:"-"`
+
+	// IsOverride is true when this entry comes from an override rather than from the layer rotation
+	IsOverride bool `json:"-"`
+
+	// OverrideID is only set when the entry was matched to an override (see MarkOverrides)
+	OverrideID string `json:"-"`
 }
 
 func (s *ScheduleEntry) String() string {
 	return fmt.Sprintf("User: %s - Start: %s - End: %s", s.User.Name, s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
 }
 
-// Schedule is a response DTO
-type Schedule struct {
+// ScheduleLayer is a response DTO
+type ScheduleLayer struct {
+	ID      string
 	Name    string
 	Entries []*ScheduleEntry `json:"rendered_schedule_entries"`
 }
 
-// ScheduleAPI contains the functions to call the schedule APIs
-type ScheduleAPI struct{}
+// Schedule is a response DTO
+type Schedule struct {
+	ID   string
+	Name string
+
+	// Entries is the final schedule (i.e. layers and overrides combined)
+	Entries []*ScheduleEntry
 
-// GetSchedule will return the schedule for the supplied id
-func (s *ScheduleAPI) GetSchedule(apiKey string, scheduleID string, start time.Time, end time.Time) (*Schedule, error) {
-	req, err := s.buildRequest(apiKey, scheduleID, start, end)
-	if err != nil {
-		return nil, err
-	}
+	Layers    []*ScheduleLayer
+	Overrides []*ScheduleEntry
+
+	// EscalationPolicyIDs are the escalation policies that use this schedule
+	EscalationPolicyIDs []string
+}
 
-	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
+// ScheduleAPI contains the functions to call the schedule APIs.
+// The zero value uses the default options (i.e. without an API key); use NewScheduleAPI to configure it
+type ScheduleAPI struct {
+	client *client
+}
+
+// NewScheduleAPI returns a ScheduleAPI configured with the supplied options
+func NewScheduleAPI(options ...Option) *ScheduleAPI {
+	return &ScheduleAPI{
+		client: newClient(options),
 	}
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+func (s *ScheduleAPI) getClient() *client {
+	if s.client == nil {
+		return defaultClient()
 	}
 
-	defer func() {
-		_ = resp.Body.Close()
-	}()
+	return s.client
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+// GetSchedule will return the schedule for the supplied id
+func (s *ScheduleAPI) GetSchedule(scheduleID string, start time.Time, end time.Time) (*Schedule, error) {
+	req, err := s.buildRequest(scheduleID, start, end)
+	if err != nil {
+		return nil, err
 	}
 
-	decoder := json.NewDecoder(resp.Body)
-
 	apiResp := &apiResponse{}
-	err = decoder.Decode(apiResp)
+	err = s.getClient().do(req, apiResp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode response to JSON with err: %s", err)
+		return nil, err
+	}
+
+	if apiResp.ScheduleOuter == nil || apiResp.ScheduleOuter.FinalSchedule == nil {
+		return nil, fmt.Errorf("schedule missing from response")
 	}
 
-	return apiResp.ScheduleOuter.Schedule, nil
+	return apiResp.ScheduleOuter.toSchedule(), nil
 }
 
-func (s *ScheduleAPI) buildRequest(apiKey string, scheduleID string, start time.Time, end time.Time) (*http.Request, error) {
-	params := &url.Values{}
+func (s *ScheduleAPI) buildRequest(scheduleID string, start time.Time, end time.Time) (*http.Request, error) {
+	params := url.Values{}
 	params.Set("time_zone", "UTC")
 	params.Set("since", start.Format(time.RFC3339))
 	params.Set("until", end.Format(time.RFC3339))
 
-	req, err := http.NewRequest("GET", apiBaseURL+"/schedules/"+scheduleID+"?"+params.Encode(), nil)
-	if err != nil {
-		return nil, err
+	return s.getClient().newRequest("GET", "/schedules/"+scheduleID, params, nil)
+}
+
+type scheduleOuter struct {
+	ID                   string
+	Name                 string
+	Layers               []*ScheduleLayer `json:"schedule_layers"`
+	OverridesSubschedule *subSchedule     `json:"overrides_subschedule"`
+	FinalSchedule        *subSchedule     `json:"final_schedule"`
+	EscalationPolicies   []*struct {
+		ID string
+	} `json:"escalation_policies"`
+}
+
+type subSchedule struct {
+	Entries []*ScheduleEntry `json:"rendered_schedule_entries"`
+}
+
+// convert the response into a Schedule, tagging each final entry with its layer and override status
+func (s *scheduleOuter) toSchedule() *Schedule {
+	out := &Schedule{
+		ID:      s.ID,
+		Name:    s.Name,
+		Entries: s.FinalSchedule.Entries,
+		Layers:  s.Layers,
+	}
+
+	if s.OverridesSubschedule != nil {
+		out.Overrides = s.OverridesSubschedule.Entries
+	}
+
+	for _, policy := range s.EscalationPolicies {
+		out.EscalationPolicyIDs = append(out.EscalationPolicyIDs, policy.ID)
+	}
+
+	for _, layer := range out.Layers {
+		for _, entry := range layer.Entries {
+			entry.LayerID = layer.ID
+			entry.LayerName = layer.Name
+		}
+	}
+
+	for _, entry := range out.Entries {
+		entry.IsOverride = findOverlapping(out.Overrides, entry, true) != nil
+
+		layerEntry := findLayerEntry(out.Layers, entry, !entry.IsOverride)
+		if layerEntry != nil {
+			entry.LayerID = layerEntry.LayerID
+			entry.LayerName = layerEntry.LayerName
+		}
 	}
 
-	req.Header.Set("Authorization", "Token token="+apiKey)
-	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
-	return req, nil
+	return out
 }
 
-type scheduleOuter struct {
-	Schedule *Schedule `json:"final_schedule"`
+// find the layer entry that overlaps the most with the supplied entry.
+// When sameUser is false, the entry for any user is acceptable (e.g. for an override the layer user is the one replaced)
+func findLayerEntry(layers []*ScheduleLayer, entry *ScheduleEntry, sameUser bool) *ScheduleEntry {
+	var out *ScheduleEntry
+	var bestOverlap time.Duration
+
+	for _, layer := range layers {
+		candidate := findOverlapping(layer.Entries, entry, sameUser)
+		if candidate == nil {
+			continue
+		}
+
+		thisOverlap := overlap(candidate, entry)
+		if thisOverlap > bestOverlap {
+			out = candidate
+			bestOverlap = thisOverlap
+		}
+	}
+
+	return out
+}
+
+// find the entry that overlaps the most with the supplied entry
+func findOverlapping(entries []*ScheduleEntry, entry *ScheduleEntry, sameUser bool) *ScheduleEntry {
+	var out *ScheduleEntry
+	var bestOverlap time.Duration
+
+	for _, candidate := range entries {
+		if sameUser && (candidate.User == nil || entry.User == nil || candidate.User.ID != entry.User.ID) {
+			continue
+		}
+
+		thisOverlap := overlap(candidate, entry)
+		if thisOverlap > bestOverlap {
+			out = candidate
+			bestOverlap = thisOverlap
+		}
+	}
+
+	return out
+}
+
+func overlap(a *ScheduleEntry, b *ScheduleEntry) time.Duration {
+	start := a.Start
+	if b.Start.After(start) {
+		start = b.Start
+	}
+
+	end := a.End
+	if b.End.Before(end) {
+		end = b.End
+	}
+
+	if !end.After(start) {
+		return 0
+	}
+
+	return end.Sub(start)
 }
@@ -4,21 +4,129 @@ import (
 	"testing"
 	"time"
 
+	"github.com/corsc/pagerduty-gcal/internal/pduty/pdtest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestScheduleAPI_GetSchedule(t *testing.T) {
-	// inputs
-	apiKey := getTestVarFromEnv(t, "TEST_PD_API_KEY")
-	scheduleID := getTestVarFromEnv(t, "TEST_PD_SCHEDULE_ID")
-	start := time.Now().Round(time.Hour)
-	end := start.Add(30 * 25 * time.Hour)
+	server := newTestServer()
+	defer server.Close()
+
+	scenarios := []struct {
+		desc            string
+		inAPIKey        string
+		inScheduleID    string
+		inStart         time.Time
+		inEnd           time.Time
+		expectedEntries int
+		expectErr       bool
+	}{
+		{
+			desc:            "happy path",
+			inAPIKey:        testAPIKey,
+			inScheduleID:    "SCHED",
+			inStart:         testStart,
+			inEnd:           testStart.Add(24 * time.Hour),
+			expectedEntries: 3,
+			expectErr:       false,
+		},
+		{
+			desc:         "sad path - unknown schedule",
+			inAPIKey:     testAPIKey,
+			inScheduleID: "UNKNOWN",
+			inStart:      testStart,
+			inEnd:        testStart.Add(24 * time.Hour),
+			expectErr:    true,
+		},
+		{
+			desc:         "sad path - bad API key",
+			inAPIKey:     "wrong",
+			inScheduleID: "SCHED",
+			inStart:      testStart,
+			inEnd:        testStart.Add(24 * time.Hour),
+			expectErr:    true,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			// call
+			api := NewScheduleAPI(WithAPIKey(scenario.inAPIKey), WithBaseURL(server.URL))
+			result, resultErr := api.GetSchedule(scenario.inScheduleID, scenario.inStart, scenario.inEnd)
+
+			// validate
+			assert.Equal(t, scenario.expectErr, resultErr != nil, scenario.desc)
+			if !scenario.expectErr {
+				assert.Equal(t, "SCHED", result.ID, scenario.desc)
+				assert.Equal(t, "Primary", result.Name, scenario.desc)
+				assert.Equal(t, scenario.expectedEntries, len(result.Entries), scenario.desc)
+				assert.Equal(t, "FOO", result.Entries[0].User.ID, scenario.desc)
+				assert.Equal(t, "Foo", result.Entries[0].User.Name, scenario.desc)
+			}
+		})
+	}
+}
+
+func TestScheduleAPI_GetSchedule_layers(t *testing.T) {
+	server := pdtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	server.AddUser(&pdtest.User{ID: "FOO", Name: "Foo"})
+	server.AddUser(&pdtest.User{ID: "BAR", Name: "Bar"})
+
+	morning := testStart
+	afternoon := testStart.Add(8 * time.Hour)
+	server.AddSchedule(&pdtest.Schedule{
+		ID: "SCHED",
+		Layers: []*pdtest.ScheduleLayer{
+			{
+				ID:      "LAYER1",
+				Name:    "Asia",
+				Entries: []*pdtest.ScheduleEntry{{Start: morning, End: afternoon, UserID: "FOO"}},
+			},
+			{
+				ID:      "LAYER2",
+				Name:    "Europe",
+				Entries: []*pdtest.ScheduleEntry{{Start: afternoon, End: afternoon.Add(8 * time.Hour), UserID: "FOO"}},
+			},
+		},
+		Entries: []*pdtest.ScheduleEntry{
+			{Start: morning, End: afternoon, UserID: "FOO"},
+			// BAR has taken the first half of the afternoon (override)
+			{Start: afternoon, End: afternoon.Add(4 * time.Hour), UserID: "BAR"},
+			{Start: afternoon.Add(4 * time.Hour), End: afternoon.Add(8 * time.Hour), UserID: "FOO"},
+		},
+	})
+
+	api := NewScheduleAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+	_, err := api.CreateOverride("SCHED", &Override{Start: afternoon, End: afternoon.Add(4 * time.Hour), User: &User{ID: "BAR"}})
+	assert.Nil(t, err)
 
 	// call
-	api := &ScheduleAPI{}
-	result, resultErr := api.GetSchedule(apiKey, scheduleID, start, end)
+	result, resultErr := api.GetSchedule("SCHED", testStart, testStart.Add(24*time.Hour))
 
 	// validate
-	assert.NotNil(t, result)
 	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result.Layers))
+	assert.Equal(t, 1, len(result.Overrides))
+
+	expected := []struct {
+		layerID    string
+		layerName  string
+		isOverride bool
+	}{
+		{layerID: "LAYER1", layerName: "Asia", isOverride: false},
+		{layerID: "LAYER2", layerName: "Europe", isOverride: true},
+		{layerID: "LAYER2", layerName: "Europe", isOverride: false},
+	}
+
+	if assert.Equal(t, len(expected), len(result.Entries)) {
+		for x, entry := range result.Entries {
+			desc := entry.String()
+			assert.Equal(t, expected[x].layerID, entry.LayerID, desc)
+			assert.Equal(t, expected[x].layerName, entry.LayerName, desc)
+			assert.Equal(t, expected[x].isOverride, entry.IsOverride, desc)
+		}
+	}
 }
@@ -1,80 +1,148 @@
 package pduty
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
-	"time"
+	"sort"
+	"sync"
 )
 
-// UserAPI contains the functions to call the User API
-type UserAPI struct{}
+// UserDetails is a response DTO
+type UserDetails struct {
+	ID       string
+	Name     string
+	Email    string
+	TimeZone string `json:"time_zone"`
+}
+
+// UserAPI contains the functions to call the User API.
+// The zero value uses the default options (i.e. without an API key); use NewUserAPI to configure it
+type UserAPI struct {
+	client *client
+}
+
+// NewUserAPI returns a UserAPI configured with the supplied options
+func NewUserAPI(options ...Option) *UserAPI {
+	return &UserAPI{
+		client: newClient(options),
+	}
+}
+
+func (u *UserAPI) getClient() *client {
+	if u.client == nil {
+		return defaultClient()
+	}
+
+	return u.client
+}
 
 // GetUsers will returns the mapping between PD user id and email
-func (u *UserAPI) GetUsers(apiKey string, entries []*ScheduleEntry) (map[string]string, error) {
+func (u *UserAPI) GetUsers(entries []*ScheduleEntry) (map[string]string, error) {
+	users, err := u.GetUserDetails(entries)
+	if err != nil {
+		return nil, err
+	}
+
 	out := map[string]string{}
+	for id, user := range users {
+		out[id] = user.Email
+	}
+
+	return out, nil
+}
+
+// GetUserDetails will return the details of every user in the entries (mapped by PD user id).
+// Each user is loaded only once, from the cache (if configured) or concurrently from PD
+func (u *UserAPI) GetUserDetails(entries []*ScheduleEntry) (map[string]*UserDetails, error) {
+	out := map[string]*UserDetails{}
+	seen := map[string]bool{}
+	var toFetch []*User
 
 	for _, entry := range entries {
-		result, err := u.getUserEmail(apiKey, entry.User)
-		if err != nil {
-			return nil, err
+		if seen[entry.User.ID] {
+			continue
 		}
+		seen[entry.User.ID] = true
 
-		out[entry.User.ID] = result
-	}
+		if cached := u.getClient().userCache.get(entry.User.ID); cached != nil {
+			out[entry.User.ID] = cached
+			continue
+		}
 
-	return out, nil
-}
+		toFetch = append(toFetch, entry.User)
+	}
 
-func (u *UserAPI) getUserEmail(apiKey string, user *User) (string, error) {
-	req, err := u.buildRequest(apiKey, user.ID)
+	fetched, err := u.fetchUsers(toFetch)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	httpClient := &http.Client{
-		Timeout: 3 * time.Second,
+	for _, user := range fetched {
+		out[user.ID] = user
+		u.getClient().userCache.set(user)
 	}
 
-	resp, err := httpClient.Do(req)
+	err = u.getClient().userCache.save()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	defer func() {
-		_ = resp.Body.Close()
-	}()
+	return out, nil
+}
 
-	decoder := json.NewDecoder(resp.Body)
+// fetch the users from PD using a bounded pool of workers
+func (u *UserAPI) fetchUsers(users []*User) ([]*UserDetails, error) {
+	var out []*UserDetails
+	mutex := &sync.Mutex{}
 
-	apiResp := &apiResponse{}
-	err = decoder.Decode(apiResp)
+	err := u.getClient().forEachUser(users, func(user *User) error {
+		result, err := u.getUser(user)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		out = append(out, result)
+		mutex.Unlock()
+		return nil
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if apiResp.UserOuter == nil {
-		return "", fmt.Errorf("WARNING: failed to load user '%s' from PD", user.Name)
-	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
 
-	return apiResp.UserOuter.Email, nil
+	return out, nil
 }
 
-func (u *UserAPI) buildRequest(apiKey string, userID string) (*http.Request, error) {
-	params := &url.Values{}
-	params.Set("id", userID)
+func (u *UserAPI) getUser(user *User) (*UserDetails, error) {
+	req, err := u.buildRequest(user.ID)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("GET", apiBaseURL+"/users/"+userID+"?"+params.Encode(), nil)
+	apiResp := &apiResponse{}
+	err = u.getClient().do(req, apiResp)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Token token="+apiKey)
-	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
-	return req, nil
+	if apiResp.UserOuter == nil {
+		return nil, fmt.Errorf("WARNING: failed to load user '%s' from PD", user.Name)
+	}
+
+	// ensure the result is keyed by the requested ID
+	apiResp.UserOuter.ID = user.ID
+
+	return apiResp.UserOuter, nil
 }
 
-type userOuter struct {
-	Email string
+func (u *UserAPI) buildRequest(userID string) (*http.Request, error) {
+	params := url.Values{}
+	params.Set("id", userID)
+
+	return u.getClient().newRequest("GET", "/users/"+userID, params, nil)
 }
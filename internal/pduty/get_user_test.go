@@ -1,29 +1,119 @@
 package pduty
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestUserAPI_GetUsers(t *testing.T) {
-	// inputs
-	apiKey := getTestVarFromEnv(t, "TEST_PD_API_KEY")
-	userID := getTestVarFromEnv(t, "TEST_PD_USER_ID")
+	server := newTestServer()
+	defer server.Close()
 
-	entries := []*ScheduleEntry{
+	scenarios := []struct {
+		desc      string
+		inUserIDs []string
+		expected  map[string]string
+		expectErr bool
+	}{
 		{
-			User: &User{
-				ID: userID,
+			desc:      "happy path",
+			inUserIDs: []string{"FOO", "BAR", "FOO"},
+			expected: map[string]string{
+				"FOO": "foo@example.com",
+				"BAR": "bar@example.com",
 			},
+			expectErr: false,
+		},
+		{
+			desc:      "sad path - unknown user",
+			inUserIDs: []string{"FOO", "UNKNOWN"},
+			expectErr: true,
 		},
 	}
 
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			var entries []*ScheduleEntry
+			for _, userID := range scenario.inUserIDs {
+				entries = append(entries, &ScheduleEntry{User: &User{ID: userID}})
+			}
+
+			// call
+			api := NewUserAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL))
+			result, resultErr := api.GetUsers(entries)
+
+			// validate
+			assert.Equal(t, scenario.expected, result, scenario.desc)
+			assert.Equal(t, scenario.expectErr, resultErr != nil, scenario.desc)
+		})
+	}
+}
+
+func TestUserAPI_GetUserDetails_deduplicates(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	var entries []*ScheduleEntry
+	for x := 0; x < 50; x++ {
+		entries = append(entries, &ScheduleEntry{User: &User{ID: "FOO"}}, &ScheduleEntry{User: &User{ID: "BAR"}})
+	}
+
 	// call
-	api := &UserAPI{}
-	result, resultErr := api.GetUsers(apiKey, entries)
+	api := NewUserAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL), WithConcurrency(3))
+	result, resultErr := api.GetUserDetails(entries)
 
 	// validate
-	assert.NotNil(t, result)
 	assert.Nil(t, resultErr)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "Foo", result["FOO"].Name)
+	assert.Equal(t, "bar@example.com", result["BAR"].Email)
+	assert.Equal(t, 1, server.RequestCount("GET", "/users/FOO"))
+	assert.Equal(t, 1, server.RequestCount("GET", "/users/BAR"))
+}
+
+func TestUserAPI_GetUserDetails_cache(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	cacheDir, err := ioutil.TempDir("", "pduty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(cacheDir)
+	}()
+
+	cacheFile := filepath.Join(cacheDir, "users.json")
+	now := time.Date(2019, 01, 01, 0, 0, 0, 0, time.UTC)
+	entries := []*ScheduleEntry{{User: &User{ID: "FOO"}}}
+
+	load := func() *UserDetails {
+		cache := NewUserCache(cacheFile, time.Hour)
+		cache.now = func() time.Time { return now }
+
+		api := NewUserAPI(WithAPIKey(testAPIKey), WithBaseURL(server.URL), WithUserCache(cache))
+		result, resultErr := api.GetUserDetails(entries)
+		assert.Nil(t, resultErr)
+		return result["FOO"]
+	}
+
+	// first call loads from PD and writes the cache
+	assert.Equal(t, "foo@example.com", load().Email)
+	assert.Equal(t, 1, server.RequestCount("GET", "/users/FOO"))
+
+	// second call (new cache instance) is served from the file
+	now = now.Add(30 * time.Minute)
+	assert.Equal(t, "foo@example.com", load().Email)
+	assert.Equal(t, 1, server.RequestCount("GET", "/users/FOO"))
+
+	// once the TTL has passed the user is loaded again
+	now = now.Add(2 * time.Hour)
+	assert.Equal(t, "foo@example.com", load().Email)
+	assert.Equal(t, 2, server.RequestCount("GET", "/users/FOO"))
 }
@@ -0,0 +1,716 @@
+// Package pdtest provides a fake PagerDuty API so that the pipeline can be tested without the real API
+package pdtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User is a PD user served by the fake
+type User struct {
+	ID       string
+	Name     string
+	Email    string
+	TimeZone string
+
+	ContactMethods    []*ContactMethod
+	NotificationRules []*NotificationRule
+}
+
+// ContactMethod is a user's contact method served by the fake (e.g. Type "phone_contact_method")
+type ContactMethod struct {
+	ID      string
+	Type    string
+	Address string
+}
+
+// NotificationRule is a user's notification rule served by the fake
+type NotificationRule struct {
+	ID              string
+	Urgency         string
+	ContactMethodID string
+}
+
+// ScheduleEntry is a rendered shift served by the fake
+type ScheduleEntry struct {
+	Start  time.Time
+	End    time.Time
+	UserID string
+}
+
+// ScheduleLayer is a layer (i.e. rotation) of a schedule served by the fake
+type ScheduleLayer struct {
+	ID      string
+	Name    string
+	Entries []*ScheduleEntry
+}
+
+// Schedule is a PD schedule served by the fake.
+// Entries is the final schedule; it is served as-is (i.e. it is not calculated from the layers and overrides)
+type Schedule struct {
+	ID      string
+	Name    string
+	Entries []*ScheduleEntry
+	Layers  []*ScheduleLayer
+}
+
+// EscalationPolicy is a PD escalation policy served by the fake; each schedule becomes a separate escalation rule
+type EscalationPolicy struct {
+	ID          string
+	Name        string
+	TeamIDs     []string
+	ScheduleIDs []string
+}
+
+// Incident is a PD incident served by the fake
+type Incident struct {
+	ID                 string
+	Title              string
+	EscalationPolicyID string
+	CreatedAt          time.Time
+
+	// zero when the incident was not acknowledged/resolved
+	AcknowledgedAt time.Time
+	ResolvedAt     time.Time
+}
+
+// Override is an override created through the fake
+type Override struct {
+	ID     string
+	Start  time.Time
+	End    time.Time
+	UserID string
+}
+
+// Server is a fake PagerDuty API; it must be closed after use
+type Server struct {
+	*httptest.Server
+
+	apiKey string
+
+	mutex     sync.Mutex
+	requests  map[string]int
+	failures  map[string][]*failure
+	users     map[string]*User
+	schedules map[string]*Schedule
+	overrides map[string][]*Override
+	policies  []*EscalationPolicy
+	incidents []*Incident
+}
+
+// NewServer starts a fake PagerDuty API that accepts only the supplied API key
+func NewServer(apiKey string) *Server {
+	out := &Server{
+		apiKey:    apiKey,
+		requests:  map[string]int{},
+		failures:  map[string][]*failure{},
+		users:     map[string]*User{},
+		schedules: map[string]*Schedule{},
+		overrides: map[string][]*Override{},
+	}
+
+	out.Server = httptest.NewServer(http.HandlerFunc(out.serveHTTP))
+	return out
+}
+
+// AddUser adds (or replaces) a user
+func (s *Server) AddUser(user *User) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.users[user.ID] = user
+}
+
+// AddSchedule adds (or replaces) a schedule
+func (s *Server) AddSchedule(schedule *Schedule) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.schedules[schedule.ID] = schedule
+}
+
+// AddEscalationPolicy adds an escalation policy
+func (s *Server) AddEscalationPolicy(policy *EscalationPolicy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.policies = append(s.policies, policy)
+}
+
+// AddIncident adds an incident
+func (s *Server) AddIncident(incident *Incident) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.incidents = append(s.incidents, incident)
+}
+
+// Overrides returns the overrides created for the supplied schedule
+func (s *Server) Overrides(scheduleID string) []*Override {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]*Override(nil), s.overrides[scheduleID]...)
+}
+
+// RequestCount returns the number of requests received for the supplied method and path (e.g. "GET", "/users/FOO")
+func (s *Server) RequestCount(method string, path string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests[method+" "+path]
+}
+
+// FailRequests will make the next count requests for the supplied method and path fail with the status code and headers
+// (e.g. 429 with a Retry-After header)
+func (s *Server) FailRequests(method string, path string, count int, statusCode int, header http.Header) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := method + " " + path
+	for x := 0; x < count; x++ {
+		s.failures[key] = append(s.failures[key], &failure{statusCode: statusCode, header: header})
+	}
+}
+
+type failure struct {
+	statusCode int
+	header     http.Header
+}
+
+func (s *Server) serveHTTP(resp http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := req.Method + " " + req.URL.Path
+	s.requests[key]++
+
+	if failures := s.failures[key]; len(failures) > 0 {
+		s.failures[key] = failures[1:]
+
+		for name, values := range failures[0].header {
+			resp.Header()[name] = values
+		}
+		writeError(resp, failures[0].statusCode, http.StatusText(failures[0].statusCode))
+		return
+	}
+
+	if req.Header.Get("Authorization") != "Token token="+s.apiKey {
+		writeError(resp, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	switch {
+	case req.Method == "GET" && len(parts) == 2 && parts[0] == "schedules":
+		s.getSchedule(resp, req, parts[1])
+
+	case req.Method == "POST" && len(parts) == 3 && parts[0] == "schedules" && parts[2] == "overrides":
+		s.createOverride(resp, req, parts[1])
+
+	case req.Method == "GET" && len(parts) == 3 && parts[0] == "schedules" && parts[2] == "overrides":
+		s.listOverrides(resp, req, parts[1])
+
+	case req.Method == "GET" && len(parts) == 2 && parts[0] == "users":
+		s.getUser(resp, parts[1])
+
+	case req.Method == "GET" && len(parts) == 3 && parts[0] == "users" && parts[2] == "contact_methods":
+		s.listContactMethods(resp, parts[1])
+
+	case req.Method == "GET" && len(parts) == 3 && parts[0] == "users" && parts[2] == "notification_rules":
+		s.listNotificationRules(resp, req, parts[1])
+
+	case req.Method == "GET" && len(parts) == 2 && parts[0] == "escalation_policies":
+		s.getEscalationPolicy(resp, parts[1])
+
+	case req.Method == "GET" && len(parts) == 1 && parts[0] == "escalation_policies":
+		s.listEscalationPolicies(resp, req)
+
+	case req.Method == "GET" && len(parts) == 1 && parts[0] == "incidents":
+		s.listIncidents(resp, req)
+
+	case req.Method == "GET" && len(parts) == 3 && parts[0] == "incidents" && parts[2] == "log_entries":
+		s.listLogEntries(resp, req, parts[1])
+
+	default:
+		writeError(resp, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) getSchedule(resp http.ResponseWriter, req *http.Request, scheduleID string) {
+	schedule, found := s.schedules[scheduleID]
+	if !found {
+		writeError(resp, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	since, until, err := parsePeriod(req)
+	if err != nil {
+		writeError(resp, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	layers := []map[string]interface{}{}
+	for _, layer := range schedule.Layers {
+		layers = append(layers, map[string]interface{}{
+			"id":                        layer.ID,
+			"name":                      layer.Name,
+			"rendered_schedule_entries": s.renderEntries(layer.Entries, since, until),
+		})
+	}
+
+	var overrides []*ScheduleEntry
+	for _, override := range s.overrides[scheduleID] {
+		overrides = append(overrides, &ScheduleEntry{Start: override.Start, End: override.End, UserID: override.UserID})
+	}
+
+	policies := []map[string]interface{}{}
+	for _, policy := range s.policies {
+		if containsAny(policy.ScheduleIDs, []string{scheduleID}) {
+			policies = append(policies, map[string]interface{}{"id": policy.ID, "type": "escalation_policy_reference"})
+		}
+	}
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"schedule": map[string]interface{}{
+			"id":                  schedule.ID,
+			"name":                schedule.Name,
+			"escalation_policies": policies,
+			"schedule_layers":     layers,
+			"overrides_subschedule": map[string]interface{}{
+				"name":                      "Overrides",
+				"rendered_schedule_entries": s.renderEntries(overrides, since, until),
+			},
+			"final_schedule": map[string]interface{}{
+				"name":                      "Final Schedule",
+				"rendered_schedule_entries": s.renderEntries(schedule.Entries, since, until),
+			},
+		},
+	})
+}
+
+// render the entries that fall within the period (clipped to the period as PD does)
+func (s *Server) renderEntries(entries []*ScheduleEntry, since time.Time, until time.Time) []map[string]interface{} {
+	out := []map[string]interface{}{}
+
+	for _, entry := range entries {
+		if !entry.End.After(since) || !entry.Start.Before(until) {
+			continue
+		}
+
+		out = append(out, map[string]interface{}{
+			"start": maxTime(entry.Start, since).Format(time.RFC3339),
+			"end":   minTime(entry.End, until).Format(time.RFC3339),
+			"user":  s.userReference(entry.UserID),
+		})
+	}
+
+	return out
+}
+
+func (s *Server) createOverride(resp http.ResponseWriter, req *http.Request, scheduleID string) {
+	if _, found := s.schedules[scheduleID]; !found {
+		writeError(resp, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	payload := &struct {
+		Override *struct {
+			Start time.Time `json:"start"`
+			End   time.Time `json:"end"`
+			User  *struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"override"`
+	}{}
+
+	err := json.NewDecoder(req.Body).Decode(payload)
+	if err != nil || payload.Override == nil || payload.Override.User == nil {
+		writeError(resp, http.StatusBadRequest, "Invalid Input Provided")
+		return
+	}
+
+	if _, found := s.users[payload.Override.User.ID]; !found {
+		writeError(resp, http.StatusBadRequest, "Invalid Input Provided")
+		return
+	}
+
+	override := &Override{
+		ID:     fmt.Sprintf("OVERRIDE%d", len(s.overrides[scheduleID])+1),
+		Start:  payload.Override.Start,
+		End:    payload.Override.End,
+		UserID: payload.Override.User.ID,
+	}
+	s.overrides[scheduleID] = append(s.overrides[scheduleID], override)
+
+	writeJSON(resp, http.StatusCreated, map[string]interface{}{
+		"override": map[string]interface{}{
+			"id":    override.ID,
+			"start": override.Start.Format(time.RFC3339),
+			"end":   override.End.Format(time.RFC3339),
+			"user":  s.userReference(override.UserID),
+		},
+	})
+}
+
+func (s *Server) listOverrides(resp http.ResponseWriter, req *http.Request, scheduleID string) {
+	if _, found := s.schedules[scheduleID]; !found {
+		writeError(resp, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	since, until, err := parsePeriod(req)
+	if err != nil {
+		writeError(resp, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	overrides := []map[string]interface{}{}
+	for _, override := range s.overrides[scheduleID] {
+		if !override.End.After(since) || !override.Start.Before(until) {
+			continue
+		}
+
+		overrides = append(overrides, map[string]interface{}{
+			"id":    override.ID,
+			"start": override.Start.Format(time.RFC3339),
+			"end":   override.End.Format(time.RFC3339),
+			"user":  s.userReference(override.UserID),
+		})
+	}
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"overrides": overrides,
+	})
+}
+
+func (s *Server) getUser(resp http.ResponseWriter, userID string) {
+	user, found := s.users[userID]
+	if !found {
+		writeError(resp, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":        user.ID,
+			"name":      user.Name,
+			"summary":   user.Name,
+			"email":     user.Email,
+			"time_zone": user.TimeZone,
+		},
+	})
+}
+
+func (s *Server) getEscalationPolicy(resp http.ResponseWriter, policyID string) {
+	for _, policy := range s.policies {
+		if policy.ID == policyID {
+			writeJSON(resp, http.StatusOK, map[string]interface{}{
+				"escalation_policy": renderEscalationPolicy(policy),
+			})
+			return
+		}
+	}
+
+	writeError(resp, http.StatusNotFound, "Not Found")
+}
+
+// supports filtering by team_ids[] and pagination with limit & offset
+func (s *Server) listEscalationPolicies(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	teamIDs := query["team_ids[]"]
+
+	var matches []*EscalationPolicy
+	for _, policy := range s.policies {
+		if len(teamIDs) == 0 || containsAny(policy.TeamIDs, teamIDs) {
+			matches = append(matches, policy)
+		}
+	}
+
+	offset, end, limit := paginate(query, len(matches))
+
+	policies := []map[string]interface{}{}
+	for _, policy := range matches[offset:end] {
+		policies = append(policies, renderEscalationPolicy(policy))
+	}
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"escalation_policies": policies,
+		"offset":              offset,
+		"limit":               limit,
+		"more":                end < len(matches),
+	})
+}
+
+// supports filtering by since & until (created time) and pagination with limit & offset
+func (s *Server) listIncidents(resp http.ResponseWriter, req *http.Request) {
+	since, until, err := parsePeriod(req)
+	if err != nil {
+		writeError(resp, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var matches []*Incident
+	for _, incident := range s.incidents {
+		if !incident.CreatedAt.Before(since) && incident.CreatedAt.Before(until) {
+			matches = append(matches, incident)
+		}
+	}
+
+	offset, end, limit := paginate(req.URL.Query(), len(matches))
+
+	incidents := []map[string]interface{}{}
+	for _, incident := range matches[offset:end] {
+		status, lastStatusChange := "triggered", incident.CreatedAt
+		acknowledgements := []map[string]interface{}{}
+
+		if !incident.AcknowledgedAt.IsZero() {
+			status, lastStatusChange = "acknowledged", incident.AcknowledgedAt
+			acknowledgements = append(acknowledgements, map[string]interface{}{
+				"at": incident.AcknowledgedAt.Format(time.RFC3339),
+			})
+		}
+
+		// as PD does, the acknowledgements are cleared when the incident is resolved
+		if !incident.ResolvedAt.IsZero() {
+			status, lastStatusChange = "resolved", incident.ResolvedAt
+			acknowledgements = []map[string]interface{}{}
+		}
+
+		incidents = append(incidents, map[string]interface{}{
+			"id":                    incident.ID,
+			"title":                 incident.Title,
+			"status":                status,
+			"urgency":               "high",
+			"created_at":            incident.CreatedAt.Format(time.RFC3339),
+			"last_status_change_at": lastStatusChange.Format(time.RFC3339),
+			"acknowledgements":      acknowledgements,
+			"escalation_policy": map[string]interface{}{
+				"id":   incident.EscalationPolicyID,
+				"type": "escalation_policy_reference",
+			},
+		})
+	}
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"incidents": incidents,
+		"offset":    offset,
+		"limit":     limit,
+		"more":      end < len(matches),
+	})
+}
+
+// supports pagination with limit & offset
+func (s *Server) listLogEntries(resp http.ResponseWriter, req *http.Request, incidentID string) {
+	var incident *Incident
+	for _, thisIncident := range s.incidents {
+		if thisIncident.ID == incidentID {
+			incident = thisIncident
+		}
+	}
+
+	if incident == nil {
+		writeError(resp, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	// newest first
+	matches := []map[string]interface{}{}
+	if !incident.ResolvedAt.IsZero() {
+		matches = append(matches, renderLogEntry("resolve_log_entry", incident.ResolvedAt))
+	}
+	if !incident.AcknowledgedAt.IsZero() {
+		matches = append(matches, renderLogEntry("acknowledge_log_entry", incident.AcknowledgedAt))
+	}
+	matches = append(matches, renderLogEntry("trigger_log_entry", incident.CreatedAt))
+
+	offset, end, limit := paginate(req.URL.Query(), len(matches))
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"log_entries": matches[offset:end],
+		"offset":      offset,
+		"limit":       limit,
+		"more":        end < len(matches),
+	})
+}
+
+func renderLogEntry(logType string, at time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       logType,
+		"created_at": at.Format(time.RFC3339),
+	}
+}
+
+func renderEscalationPolicy(policy *EscalationPolicy) map[string]interface{} {
+	rules := []map[string]interface{}{}
+	for _, scheduleID := range policy.ScheduleIDs {
+		rules = append(rules, map[string]interface{}{
+			"escalation_delay_in_minutes": 30,
+			"targets": []map[string]interface{}{
+				{"id": scheduleID, "type": "schedule_reference"},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"id":               policy.ID,
+		"name":             policy.Name,
+		"escalation_rules": rules,
+	}
+}
+
+func (s *Server) listContactMethods(resp http.ResponseWriter, userID string) {
+	user, found := s.users[userID]
+	if !found {
+		writeError(resp, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	contactMethods := []map[string]interface{}{}
+	for _, contactMethod := range user.ContactMethods {
+		contactMethods = append(contactMethods, map[string]interface{}{
+			"id":      contactMethod.ID,
+			"type":    contactMethod.Type,
+			"address": contactMethod.Address,
+		})
+	}
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"contact_methods": contactMethods,
+	})
+}
+
+// supports filtering by urgency
+func (s *Server) listNotificationRules(resp http.ResponseWriter, req *http.Request, userID string) {
+	user, found := s.users[userID]
+	if !found {
+		writeError(resp, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	urgency := req.URL.Query().Get("urgency")
+
+	rules := []map[string]interface{}{}
+	for _, rule := range user.NotificationRules {
+		if urgency != "" && urgency != "all" && rule.Urgency != urgency {
+			continue
+		}
+
+		contactMethod := map[string]interface{}{
+			"id": rule.ContactMethodID,
+		}
+		for _, thisMethod := range user.ContactMethods {
+			if thisMethod.ID == rule.ContactMethodID {
+				contactMethod["type"] = thisMethod.Type + "_reference"
+			}
+		}
+
+		rules = append(rules, map[string]interface{}{
+			"id":                     rule.ID,
+			"urgency":                rule.Urgency,
+			"start_delay_in_minutes": 0,
+			"contact_method":         contactMethod,
+		})
+	}
+
+	writeJSON(resp, http.StatusOK, map[string]interface{}{
+		"notification_rules": rules,
+	})
+}
+
+func (s *Server) userReference(userID string) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":   userID,
+		"type": "user_reference",
+	}
+
+	if user, found := s.users[userID]; found {
+		out["summary"] = user.Name
+	}
+
+	return out
+}
+
+func parsePeriod(req *http.Request) (time.Time, time.Time, error) {
+	since, err := time.Parse(time.RFC3339, req.URL.Query().Get("since"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid since: %s", err)
+	}
+
+	until, err := time.Parse(time.RFC3339, req.URL.Query().Get("until"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid until: %s", err)
+	}
+
+	return since, until, nil
+}
+
+// returns the range of items to return based on the offset & limit params
+func paginate(query url.Values, total int) (int, int, int) {
+	offset, limit := parseInt(query.Get("offset"), 0), parseInt(query.Get("limit"), 25)
+	if offset > total {
+		offset = total
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return offset, end, limit
+}
+
+func parseInt(value string, defaultValue int) int {
+	out, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return out
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, value := range values {
+		for _, thisWanted := range wanted {
+			if value == thisWanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeJSON(resp http.ResponseWriter, statusCode int, body interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(statusCode)
+	_ = json.NewEncoder(resp).Encode(body)
+}
+
+func writeError(resp http.ResponseWriter, statusCode int, message string) {
+	writeJSON(resp, statusCode, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+		},
+	})
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,165 @@
+package pduty
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBaseDelay  = 500 * time.Millisecond
+	defaultMaxDelay   = 20 * time.Second
+)
+
+// retryTransport will retry requests that were rate limited (HTTP 429) or failed with a server error (HTTP 5xx).
+// Rate limited requests wait as long as PD asks (via the rate limit headers); all others use exponential backoff with
+// jitter.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+
+	// used in tests
+	sleep  func(req *http.Request, delay time.Duration) error
+	jitter func() float64
+}
+
+func newRetryTransport(next http.RoundTripper, maxRetries int, baseDelay time.Duration) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &retryTransport{
+		next:       next,
+		maxRetries: maxRetries,
+		baseDelay:  baseDelay,
+		maxDelay:   defaultMaxDelay,
+		sleep:      sleepWithContext,
+		jitter:     rand.Float64,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		thisReq, err := r.rewind(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := r.next.RoundTrip(thisReq)
+		if attempt >= r.maxRetries || !r.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		delay := r.delay(attempt, resp)
+		if resp != nil {
+			// discard the body so that the connection can be reused
+			_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+			_ = resp.Body.Close()
+		}
+
+		err = r.sleep(req, delay)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retries need a fresh copy of the request body
+func (r *retryTransport) rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = body
+	return out, nil
+}
+
+func (r *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	// requests that were rate limited were not processed so it is always safe to retry them
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	// otherwise the request may have been processed and only idempotent requests are safe to retry
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+func (r *retryTransport) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		if delay, found := rateLimitDelay(resp.Header); found {
+			if delay > r.maxDelay {
+				return r.maxDelay
+			}
+			return delay
+		}
+	}
+
+	// exponential backoff with jitter (between 50% and 100% of the backoff)
+	backoff := r.baseDelay << uint(attempt)
+	if backoff > r.maxDelay || backoff <= 0 {
+		backoff = r.maxDelay
+	}
+
+	return backoff/2 + time.Duration(r.jitter()*float64(backoff/2))
+}
+
+// PD sends the seconds to wait in the Retry-After header (and the seconds until the limit resets in ratelimit-reset)
+func rateLimitDelay(header http.Header) (time.Duration, bool) {
+	for _, key := range []string{"Retry-After", "Ratelimit-Reset"} {
+		value := header.Get(key)
+		if value == "" {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(value)
+		if err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		// Retry-After can also be a HTTP date
+		retryAt, err := http.ParseTime(value)
+		if err == nil {
+			delay := time.Until(retryAt)
+			if delay < 0 {
+				delay = 0
+			}
+			return delay, true
+		}
+	}
+
+	return 0, false
+}
+
+func sleepWithContext(req *http.Request, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
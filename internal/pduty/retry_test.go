@@ -0,0 +1,197 @@
+package pduty
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubTransport struct {
+	responses []*http.Response
+	bodies    []string
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		payload, _ := ioutil.ReadAll(req.Body)
+		s.bodies = append(s.bodies, string(payload))
+	}
+
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func stubResponse(statusCode int, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}
+}
+
+func TestRetryTransport_RoundTrip(t *testing.T) {
+	scenarios := []struct {
+		desc               string
+		inMethod           string
+		inResponses        []*http.Response
+		expectedStatusCode int
+		expectedDelays     []time.Duration
+	}{
+		{
+			desc:               "happy path - no retry",
+			inMethod:           "GET",
+			inResponses:        []*http.Response{stubResponse(200, nil)},
+			expectedStatusCode: 200,
+			expectedDelays:     nil,
+		},
+		{
+			desc:     "rate limited - honours Retry-After",
+			inMethod: "GET",
+			inResponses: []*http.Response{
+				stubResponse(429, http.Header{"Retry-After": []string{"2"}}),
+				stubResponse(200, nil),
+			},
+			expectedStatusCode: 200,
+			expectedDelays:     []time.Duration{2 * time.Second},
+		},
+		{
+			desc:     "rate limited - honours ratelimit-reset",
+			inMethod: "POST",
+			inResponses: []*http.Response{
+				stubResponse(429, http.Header{"Ratelimit-Reset": []string{"7"}}),
+				stubResponse(201, nil),
+			},
+			expectedStatusCode: 201,
+			expectedDelays:     []time.Duration{7 * time.Second},
+		},
+		{
+			desc:     "server error - exponential backoff then give up",
+			inMethod: "GET",
+			inResponses: []*http.Response{
+				stubResponse(500, nil),
+				stubResponse(502, nil),
+				stubResponse(503, nil),
+				stubResponse(503, nil),
+			},
+			expectedStatusCode: 503,
+			expectedDelays:     []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			desc:     "server error - no retry for non-idempotent request",
+			inMethod: "POST",
+			inResponses: []*http.Response{
+				stubResponse(500, nil),
+			},
+			expectedStatusCode: 500,
+			expectedDelays:     nil,
+		},
+		{
+			desc:     "client error - no retry",
+			inMethod: "GET",
+			inResponses: []*http.Response{
+				stubResponse(404, nil),
+			},
+			expectedStatusCode: 404,
+			expectedDelays:     nil,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			var delays []time.Duration
+			stub := &stubTransport{responses: scenario.inResponses}
+
+			transport := newRetryTransport(stub, 3, time.Second)
+			transport.sleep = func(_ *http.Request, delay time.Duration) error {
+				delays = append(delays, delay)
+				return nil
+			}
+			// maximum jitter
+			transport.jitter = func() float64 { return 1 }
+
+			req, err := http.NewRequest(scenario.inMethod, "http://example.com", bytes.NewReader([]byte("body")))
+			assert.Nil(t, err)
+
+			// call
+			resp, resultErr := transport.RoundTrip(req)
+
+			// validate
+			assert.Nil(t, resultErr, scenario.desc)
+			assert.Equal(t, scenario.expectedStatusCode, resp.StatusCode, scenario.desc)
+			assert.Equal(t, scenario.expectedDelays, delays, scenario.desc)
+			for _, body := range stub.bodies {
+				assert.Equal(t, "body", body, "retries must resend the body")
+			}
+		})
+	}
+}
+
+func TestClient_typedErrors(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	rateLimited := http.Header{"Retry-After": []string{"0"}}
+
+	scenarios := []struct {
+		desc        string
+		inAPIKey    string
+		inFailures  int
+		inStatus    int
+		inHeader    http.Header
+		expectedErr error
+	}{
+		{
+			desc:        "unauthorized",
+			inAPIKey:    "wrong",
+			expectedErr: ErrUnauthorized,
+		},
+		{
+			desc:        "rate limited (recovers)",
+			inAPIKey:    testAPIKey,
+			inFailures:  2,
+			inStatus:    http.StatusTooManyRequests,
+			inHeader:    rateLimited,
+			expectedErr: nil,
+		},
+		{
+			desc:        "rate limited (gives up)",
+			inAPIKey:    testAPIKey,
+			inFailures:  4,
+			inStatus:    http.StatusTooManyRequests,
+			inHeader:    rateLimited,
+			expectedErr: ErrRateLimited,
+		},
+		{
+			desc:        "server error",
+			inAPIKey:    testAPIKey,
+			inFailures:  4,
+			inStatus:    http.StatusServiceUnavailable,
+			expectedErr: ErrServerError,
+		},
+	}
+
+	for _, s := range scenarios {
+		scenario := s
+		t.Run(scenario.desc, func(t *testing.T) {
+			server.FailRequests("GET", "/users/FOO", scenario.inFailures, scenario.inStatus, scenario.inHeader)
+
+			// call
+			api := NewUserAPI(WithAPIKey(scenario.inAPIKey), WithBaseURL(server.URL), WithRetries(3, time.Millisecond))
+			_, resultErr := api.GetUsers([]*ScheduleEntry{{User: &User{ID: "FOO"}}})
+
+			// validate
+			assert.True(t, errors.Is(resultErr, scenario.expectedErr), scenario.desc)
+		})
+	}
+}
@@ -1,15 +1,41 @@
 package pduty
 
 import (
-	"os"
-	"testing"
+	"time"
+
+	"github.com/corsc/pagerduty-gcal/internal/pduty/pdtest"
 )
 
-func getTestVarFromEnv(t *testing.T, envVar string) string {
-	out, found := os.LookupEnv(envVar)
-	if !found {
-		t.Skipf("test skipped due to lack of environment variable %s", envVar)
+const testAPIKey = "test-api-key"
+
+var (
+	testStart = time.Date(2019, 01, 01, 0, 0, 0, 0, time.UTC)
+)
+
+// start a fake PD with 2 users sharing a schedule that alternates every 8 hours for 3 days
+func newTestServer() *pdtest.Server {
+	server := pdtest.NewServer(testAPIKey)
+
+	server.AddUser(&pdtest.User{ID: "FOO", Name: "Foo", Email: "foo@example.com"})
+	server.AddUser(&pdtest.User{ID: "BAR", Name: "Bar", Email: "bar@example.com"})
+
+	schedule := &pdtest.Schedule{
+		ID:   "SCHED",
+		Name: "Primary",
 	}
 
-	return out
+	userIDs := []string{"FOO", "BAR"}
+	for x := 0; x < 9; x++ {
+		start := testStart.Add(time.Duration(x*8) * time.Hour)
+
+		schedule.Entries = append(schedule.Entries, &pdtest.ScheduleEntry{
+			Start:  start,
+			End:    start.Add(8 * time.Hour),
+			UserID: userIDs[x%len(userIDs)],
+		})
+	}
+
+	server.AddSchedule(schedule)
+
+	return server
 }
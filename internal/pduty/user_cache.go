@@ -0,0 +1,121 @@
+package pduty
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// UserCache is a file based cache of user details.
+// A nil *UserCache is valid and caches nothing.
+type UserCache struct {
+	path string
+	ttl  time.Duration
+
+	// used in tests
+	now func() time.Time
+
+	mutex   sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]*cachedUser
+}
+
+type cachedUser struct {
+	User    *UserDetails
+	Fetched time.Time
+}
+
+// NewUserCache returns a cache stored in the supplied file; entries older than ttl are ignored
+func NewUserCache(path string, ttl time.Duration) *UserCache {
+	return &UserCache{
+		path: path,
+		ttl:  ttl,
+		now:  time.Now,
+	}
+}
+
+func (c *UserCache) get(userID string) *UserDetails {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.load()
+
+	entry, found := c.entries[userID]
+	if !found || c.now().Sub(entry.Fetched) > c.ttl {
+		return nil
+	}
+
+	return entry.User
+}
+
+func (c *UserCache) set(user *UserDetails) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.load()
+
+	c.entries[user.ID] = &cachedUser{
+		User:    user,
+		Fetched: c.now(),
+	}
+	c.dirty = true
+}
+
+// save will write the cache to disk (when it has changed)
+func (c *UserCache) save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	payload, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(c.path, payload, 0600)
+	if err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// load the cache from disk; a missing or corrupt file is treated as an empty cache
+func (c *UserCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = map[string]*cachedUser{}
+
+	payload, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+
+	entries := map[string]*cachedUser{}
+	err = json.Unmarshal(payload, &entries)
+	if err != nil {
+		return
+	}
+
+	c.entries = entries
+}
@@ -1,30 +1,61 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/corsc/pagerduty-gcal/internal/conflict"
 	"github.com/corsc/pagerduty-gcal/internal/gcal"
+	"github.com/corsc/pagerduty-gcal/internal/holiday"
+	"github.com/corsc/pagerduty-gcal/internal/ics"
+	"github.com/corsc/pagerduty-gcal/internal/identity"
+	"github.com/corsc/pagerduty-gcal/internal/msgraph"
 	"github.com/corsc/pagerduty-gcal/internal/pduty"
 )
 
 // NOTES:
-// * For this tool to work it requires a public calendar event with the word "out" in the title.
-// 	 Using the "out of office" event type in the google calendar UI will achieve this.
+// * For this tool to work it requires a calendar event that matches one of the calendar rules (see -calendar-rules).
+// 	 By default using the "out of office" event type in the google calendar UI will achieve this.
 
 const (
 	timeFormat = "2006-01-02 15:04"
 )
 
 var (
-	scheduleID        string
+	scheduleIDs       string
+	policyID          string
+	teamID            string
 	startAsString     string
 	days              int64
 	daysBetweenShifts int64
+	hoursOfRest       int64
+	userCacheFile     string
+	calendarRulesFile string
+	calendarSources   string
+	calendarSource    string
+	freeBusyAllDay    bool
+	freeBusyMin       time.Duration
+	gcalAuthMode      string
+	gcalCacheFile     string
+	gcalCredentials   string
+	gcalSubject       string
+	gcalTokenFile     string
+	gcalTokenStore    string
+	holidaysFile      string
+	identityFile      string
+	syncCalendar      string
+	userCacheTTL      time.Duration
+	lockOverrides     bool
+	loadDays          int64
+	applyOverrides    bool
+	assumeYes         bool
 )
 
 func main() {
@@ -33,11 +64,41 @@ func main() {
 	if !found {
 		panic("PD_API_KEY must be set")
 	}
-	flag.StringVar(&scheduleID, "schedule", "", "schedule id (see README.md) for more info")
+	flag.StringVar(&scheduleIDs, "schedule", "", "comma separated list of schedule ids (see README.md) for more info")
+	flag.StringVar(&policyID, "escalation-policy", "", "escalation policy id; all schedules in the policy are checked")
+	flag.StringVar(&teamID, "team", "", "team id; all schedules in the team's escalation policies are checked")
 	flag.StringVar(&startAsString, "start", "", "start of the schedule")
 	flag.Int64Var(&days, "days", 30, "days to add to start to define the schedule")
 	flag.Int64Var(&daysBetweenShifts, "between", 3, "minimum number of days between shifts")
-	flag.Parse()
+	flag.Int64Var(&hoursOfRest, "rest", 8, "minimum number of hours between shifts on different schedules")
+	flag.StringVar(&userCacheFile, "user-cache", "", "file used to cache PagerDuty user details (default is no cache)")
+	flag.DurationVar(&userCacheTTL, "user-cache-ttl", 24*time.Hour, "maximum age of the cached PagerDuty user details")
+	flag.Int64Var(&loadDays, "load-days", 0, "days of incident history used to report on-call load and prefer less loaded users for swaps (default is disabled)")
+	flag.StringVar(&calendarRulesFile, "calendar-rules", "", "JSON file of rules that decide which calendar events make a user unavailable (default is out of office events and the words \"out\" and \"xoncall\")")
+	flag.StringVar(&calendarSources, "calendar-sources", "", "JSON file that assigns users to calendar providers (e.g. ICS feeds); users not listed use Google Calendar")
+	flag.StringVar(&calendarSource, "calendar-source", gcal.SourceEvents, "where unavailability comes from; either \"events\" (matched against the calendar rules) or \"freebusy\" (busy blocks, works with private calendars)")
+	flag.BoolVar(&freeBusyAllDay, "freebusy-all-day", true, "with -calendar-source=freebusy, treat busy blocks that cover a whole day as unavailability")
+	flag.DurationVar(&freeBusyMin, "freebusy-min", 0, "with -calendar-source=freebusy, treat busy blocks at least this long as unavailability (default is disabled)")
+	flag.StringVar(&gcalAuthMode, "gcal-auth", gcal.AuthModeOAuth, "how to access Google Calendar; either \"oauth\" (browser sign in) or \"service-account\" (key with domain-wide delegation)")
+	flag.StringVar(&gcalCacheFile, "gcal-cache", "", "file used to cache Google Calendar events between runs so that only the changes are loaded (default is no cache)")
+	flag.StringVar(&gcalCredentials, "gcal-credentials", "credentials.json", "Google OAuth client credentials or (with -gcal-auth=service-account) the service account key")
+	flag.StringVar(&gcalSubject, "gcal-subject", "", "with -gcal-auth=service-account, the user to impersonate (default is to impersonate each scheduled user)")
+	flag.StringVar(&gcalTokenFile, "gcal-token", "token.json", "file used to store the Google sign in")
+	flag.StringVar(&gcalTokenStore, "gcal-token-store", "file", "how the Google sign in is stored; either \"file\", \"encrypted\" (passphrase from GCAL_TOKEN_PASSPHRASE) or \"env\" (token JSON from GCAL_TOKEN, use \"-\" to read stdin)")
+	flag.StringVar(&identityFile, "identity-map", "", "JSON file that maps PagerDuty user emails to calendar emails (default is to use the PagerDuty emails)")
+	flag.StringVar(&holidaysFile, "holidays", "", "JSON file that assigns users to public holiday regions; shifts on a holiday are reported as soft conflicts (default is disabled)")
+	flag.BoolVar(&lockOverrides, "lock-overrides", true, "never propose swaps involving shifts covered by an existing override")
+	flag.BoolVar(&applyOverrides, "apply", false, "create PagerDuty overrides for the proposed swaps (default is a dry-run)")
+	flag.BoolVar(&assumeYes, "yes", false, "do not ask for confirmation before creating overrides")
+
+	// "pdgcal sync [flags]" publishes the shifts to Google Calendar instead of checking them
+	syncCommand := len(os.Args) > 1 && os.Args[1] == "sync"
+	if syncCommand {
+		flag.StringVar(&syncCalendar, "calendar", "", "with sync, the ID of a shared calendar to publish all shifts to (default is each user's own calendar)")
+		_ = flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 
 	periodStart, err := time.Parse("2006-01-02", startAsString)
 	if err != nil {
@@ -53,63 +114,610 @@ func main() {
 	}
 
 	end := periodStart.Add(time.Duration(days) * 24 * time.Hour)
-	credentialsFile := "credentials.json"
-	tokenFile := "token.json"
+	credentialsFile := gcalCredentials
+	tokenFile := gcalTokenFile
 
 	// actual logic
-	fmt.Printf("Loading schedule for %s to %s\n", periodStart.Format(timeFormat), end.Format(timeFormat))
+	pdOptions := []pduty.Option{pduty.WithAPIKey(apiKey)}
+	if userCacheFile != "" {
+		pdOptions = append(pdOptions, pduty.WithUserCache(pduty.NewUserCache(userCacheFile, userCacheTTL)))
+	}
+	scheduleAPI := pduty.NewScheduleAPI(pdOptions...)
+
 	scheduleStart := periodStart.Add(time.Duration(-daysBetweenShifts*24) * time.Hour)
-	schedule, err := (&pduty.ScheduleAPI{}).GetSchedule(apiKey, scheduleID, scheduleStart, end)
+	scheduleEnd := end
+	if syncCommand {
+		scheduleStart, scheduleEnd = periodStart.Add(-gcal.SyncMargin), end.Add(gcal.SyncMargin)
+	}
+	var schedules []*pduty.Schedule
+	var allEntries []*pduty.ScheduleEntry
+
+	allScheduleIDs, err := resolveScheduleIDs(pduty.NewEscalationPolicyAPI(pdOptions...))
 	if err != nil {
-		fmt.Print(err)
+		fmt.Printf("failed to find schedules: %s\n", describePDError(err))
 		return
 	}
 
+	if len(allScheduleIDs) == 0 {
+		fmt.Print("no schedules found; please supply -schedule, -escalation-policy or -team\n")
+		flag.PrintDefaults()
+		return
+	}
+
+	for _, scheduleID := range allScheduleIDs {
+		fmt.Printf("Loading schedule %s for %s to %s\n", scheduleID, periodStart.Format(timeFormat), end.Format(timeFormat))
+		schedule, err := scheduleAPI.GetSchedule(scheduleID, scheduleStart, scheduleEnd)
+		if err != nil {
+			fmt.Printf("failed to load schedule %s: %s\n", scheduleID, describePDError(err))
+			return
+		}
+
+		overrides, err := scheduleAPI.GetOverrides(schedule.ID, scheduleStart, scheduleEnd)
+		if err != nil {
+			fmt.Printf("failed to load overrides for schedule %s: %s\n", scheduleID, describePDError(err))
+			return
+		}
+		pduty.MarkOverrides(schedule, overrides)
+
+		schedules = append(schedules, schedule)
+		allEntries = append(allEntries, schedule.Entries...)
+	}
+
 	fmt.Printf("Loading scheduled user details\n")
-	participants, err := (&pduty.UserAPI{}).GetUsers(apiKey, schedule.Entries)
+	participants, err := pduty.NewUserAPI(pdOptions...).GetUsers(allEntries)
 	if err != nil {
-		fmt.Print(err)
+		// without the emails no calendar can be checked; continuing would report every shift as conflict free
+		fmt.Printf("failed to load users: %s\n", describePDError(err))
+		return
+	}
+
+	if identityFile != "" {
+		participants, err = mapIdentities(participants, allEntries)
+		if err != nil {
+			fmt.Printf("failed to map user emails: %s\n", err)
+			return
+		}
+	}
+
+	if syncCommand {
+		syncSchedules(schedules, participants, periodStart, end)
+		return
+	}
+
+	fmt.Printf("Loading notification setup for scheduled users\n")
+	// users whose setup failed to load are reported as "notification setup unknown"
+	setups, err := pduty.NewUserAPI(pdOptions...).GetNotificationSetups(allEntries)
+	if err != nil {
+		fmt.Printf("failed to load notification setup: %s\n", describePDError(err))
+	}
+
+	calendarAPI := &gcal.CalendarAPI{
+		Source:   calendarSource,
+		AuthMode: gcalAuthMode,
+		Subject:  gcalSubject,
+		Reauthenticate: func() bool {
+			return confirm("Your Google sign in has expired or been revoked. Sign in again?")
+		},
+		FreeBusy: gcal.FreeBusyOptions{
+			AllDay:      freeBusyAllDay,
+			MinDuration: freeBusyMin,
+		},
+	}
+	calendarAPI.Tokens, err = newTokenStore(tokenFile)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		flag.PrintDefaults()
+		return
+	}
+
+	if gcalCacheFile != "" {
+		calendarAPI.Cache = gcal.NewEventCache(gcalCacheFile)
+	}
+
+	if calendarRulesFile != "" {
+		calendarAPI.Rules, err = gcal.LoadRules(calendarRulesFile)
+		if err != nil {
+			fmt.Printf("failed to load calendar rules: %s\n", err)
+			return
+		}
+	}
+
+	var calendarProvider gcal.Provider = &gcal.GoogleProvider{
+		API:             calendarAPI,
+		CredentialsFile: credentialsFile,
+		TokenFile:       tokenFile,
+	}
+	if calendarSources != "" {
+		calendarProvider, err = newCalendarRouter(calendarProvider, calendarAPI.Rules)
+		if err != nil {
+			fmt.Printf("failed to load calendar sources: %s\n", err)
+			return
+		}
 	}
 
 	fmt.Printf("Loading calendars for scheduled users\n")
-	calendars, err := (&gcal.CalendarAPI{}).GetCalendars(credentialsFile, tokenFile, participants, periodStart, end)
+	calendars, err := calendarProvider.GetCalendars(participants, periodStart, end)
 	if err != nil {
 		fmt.Print(err)
 		return
 	}
+	printCalendarWarnings(calendars)
+	printUnavailableCalendars(calendars, participants)
+
+	var holidays map[string][]*holiday.Holiday
+	if holidaysFile != "" {
+		holidays, err = loadHolidays(participants, calendars, periodStart, end)
+		if err != nil {
+			fmt.Printf("failed to load public holidays: %s\n", err)
+			return
+		}
+	}
+
+	for _, schedule := range schedules {
+		fmt.Printf("\nSchedule: %s (%s)\n", schedule.Name, schedule.ID)
+
+		conflicts := checkForConflicts(periodStart, schedule, calendars, daysBetweenShifts)
+		checkForUnknown(schedule, calendars)
+		if holidays != nil {
+			checkHolidays(schedule, holidays, conflicts)
+		}
+		if setups != nil {
+			checkReachability(schedule, setups)
+		}
+
+		var loads map[string]*conflict.UserLoad
+		if loadDays > 0 {
+			loads = reportLoad(pdOptions, schedule)
+		}
+
+		if len(conflicts) == 0 {
+			continue
+		}
+
+		swaps := findSwaps(periodStart, schedule, conflicts, calendars, loads)
+		if len(swaps) == 0 {
+			continue
+		}
+
+		applySwaps(scheduleAPI, schedule, conflicts, swaps)
+	}
+
+	if len(schedules) > 1 {
+		checkForDoubleBookings(schedules, hoursOfRest)
+	}
+}
+
+// combine the schedules supplied directly with those referenced by the escalation policy and team (without duplicates)
+func resolveScheduleIDs(policyAPI *pduty.EscalationPolicyAPI) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+
+	add := func(ids ...string) {
+		for _, id := range ids {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+
+	add(strings.Split(scheduleIDs, ",")...)
+
+	if policyID != "" {
+		fmt.Printf("Loading escalation policy %s\n", policyID)
+		policy, err := policyAPI.GetEscalationPolicy(policyID)
+		if err != nil {
+			return nil, err
+		}
+
+		add(policy.ScheduleIDs...)
+	}
+
+	if teamID != "" {
+		fmt.Printf("Loading escalation policies for team %s\n", teamID)
+		policies, err := policyAPI.GetTeamEscalationPolicies(teamID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, policy := range policies {
+			add(policy.ScheduleIDs...)
+		}
+	}
+
+	return out, nil
+}
+
+// users listed in the -calendar-sources file are loaded from their provider, everyone else from Google Calendar
+func newCalendarRouter(google gcal.Provider, rules []*gcal.Rule) (gcal.Provider, error) {
+	sources, err := gcal.LoadUserSources(calendarSources)
+	if err != nil {
+		return nil, err
+	}
+
+	icsSources := map[string]string{}
+	for user, source := range sources {
+		if source.Provider == ics.ProviderName {
+			icsSources[user] = source.Source
+		}
+	}
+
+	return &gcal.Router{
+		Default: google,
+		Providers: map[string]gcal.Provider{
+			gcal.ProviderGoogle: google,
+			ics.ProviderName:    &ics.Provider{Sources: icsSources, Rules: rules},
+			msgraph.ProviderName: &msgraph.Provider{
+				TenantID:     os.Getenv("MSGRAPH_TENANT_ID"),
+				ClientID:     os.Getenv("MSGRAPH_CLIENT_ID"),
+				ClientSecret: os.Getenv("MSGRAPH_CLIENT_SECRET"),
+				Rules:        rules,
+			},
+		},
+		Sources: sources,
+	}, nil
+}
+
+func newTokenStore(tokenFile string) (gcal.TokenStore, error) {
+	switch gcalTokenStore {
+	case "file":
+		return &gcal.FileTokenStore{Path: tokenFile}, nil
+
+	case "encrypted":
+		passphrase, found := os.LookupEnv("GCAL_TOKEN_PASSPHRASE")
+		if !found {
+			return nil, errors.New("GCAL_TOKEN_PASSPHRASE must be set when using -gcal-token-store=encrypted")
+		}
+		return &gcal.EncryptedFileTokenStore{Path: tokenFile, Passphrase: passphrase}, nil
+
+	case "env":
+		return &gcal.EnvTokenStore{Variable: "GCAL_TOKEN"}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown token store '%s'", gcalTokenStore)
+	}
+}
+
+func printCalendarWarnings(calendars map[string]*gcal.Calendar) {
+	var ids []string
+	for id := range calendars {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for _, warning := range calendars[id].Warnings {
+			fmt.Printf("WARNING: %s\n", warning)
+		}
+	}
+}
+
+// list the calendars that could not be loaded; the shifts of these users are reported as unknown
+func printUnavailableCalendars(calendars map[string]*gcal.Calendar, participants map[string]string) {
+	var ids []string
+	for id, calendar := range calendars {
+		if calendar.Err != nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Strings(ids)
 
-	conflicts := checkForConflicts(schedule, calendars, daysBetweenShifts)
-	if len(conflicts) == 0 {
+	fmt.Printf("\nCalendar unavailable (user : reason)\n")
+	for _, id := range ids {
+		fmt.Printf("%s : %s\n", participants[id], calendars[id].Err)
+	}
+	fmt.Printf("\n")
+}
+
+func checkForUnknown(schedule *pduty.Schedule, calendars map[string]*gcal.Calendar) {
+	unknowns, err := (&conflict.CheckerAPI{}).CheckUnknown(schedule, calendars)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(unknowns) == 0 {
 		return
 	}
 
-	_ = findSwaps(periodStart, schedule, conflicts, calendars)
+	fmt.Printf("Unknown - calendar unavailable (slot : user)\n")
+	for _, scheduleEntry := range unknowns {
+		fmt.Printf("%s to %s : %s%s\n", scheduleEntry.Start.Format(timeFormat), scheduleEntry.End.Format(timeFormat), scheduleEntry.User.Name, overrideLabel(scheduleEntry))
+	}
 }
 
-func checkForConflicts(schedule *pduty.Schedule, calendars map[string]*gcal.Calendar, daysBetweenShifts int64) []*pduty.ScheduleEntry {
+func checkForConflicts(periodStart time.Time, schedule *pduty.Schedule, calendars map[string]*gcal.Calendar, daysBetweenShifts int64) []*pduty.ScheduleEntry {
 	fmt.Printf("Checking for conflicts\n")
-	conflictsOrdered, err := (&conflict.CheckerAPI{}).Check(schedule, calendars, daysBetweenShifts)
+	found, err := (&conflict.CheckerAPI{}).Check(schedule, calendars, daysBetweenShifts)
 	if err != nil {
 		panic(err)
 	}
 
+	// shifts before the period are only loaded to check the days between shifts
+	var conflictsOrdered []*conflict.Conflict
+	for _, thisConflict := range found {
+		if !thisConflict.Entry.Start.Before(periodStart) {
+			conflictsOrdered = append(conflictsOrdered, thisConflict)
+		}
+	}
+
 	// output result
 	if len(conflictsOrdered) == 0 {
 		log.Printf("No conflicts found")
 		return nil
 	}
 
-	fmt.Printf("Conflict (slot : user)\n")
-	for _, scheduleEntry := range conflictsOrdered {
-		fmt.Printf("%s to %s : %s\n", scheduleEntry.Start.Format(timeFormat), scheduleEntry.End.Format(timeFormat), scheduleEntry.User.Name)
+	var entries []*pduty.ScheduleEntry
+
+	fmt.Printf("Conflict (slot : user : severity : reason)\n")
+	for _, found := range conflictsOrdered {
+		scheduleEntry := found.Entry
+		fmt.Printf("%s to %s : %s%s : %s : %s\n", scheduleEntry.Start.Format(timeFormat), scheduleEntry.End.Format(timeFormat), scheduleEntry.User.Name, overrideLabel(scheduleEntry),
+			found.Severity, describeConflict(found))
+		entries = append(entries, scheduleEntry)
+	}
+
+	return entries
+}
+
+// explain the conflict so that the user knows what to fix
+func describeConflict(found *conflict.Conflict) string {
+	switch found.Reason {
+	case conflict.ReasonMinimumDays:
+		previous := found.PreviousShift
+		return fmt.Sprintf("%s (previous shift %s to %s, %s too soon)", found.Reason,
+			previous.Start.Format(timeFormat), previous.End.Format(timeFormat), found.Overlap)
+
+	case conflict.ReasonCalendar:
+		event := found.Event
+		out := fmt.Sprintf("%s \"%s\" %s to %s (%s, overlaps %s)", found.Reason, event.Summary,
+			event.Start.Format(timeFormat), event.End.Format(timeFormat), event.Rule, found.Overlap)
+		if event.HTMLLink != "" {
+			out += " " + event.HTMLLink
+		}
+		return out
+	}
+
+	return found.Reason
+}
+
+// convert the PD emails into calendar emails and report the users that no mapping applied to
+func mapIdentities(participants map[string]string, entries []*pduty.ScheduleEntry) (map[string]string, error) {
+	mapper, err := identity.LoadMapper(identityFile)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped, unmapped, err := mapper.Map(participants)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(unmapped) > 0 {
+		names := map[string]string{}
+		for _, entry := range entries {
+			names[entry.User.ID] = entry.User.Name
+		}
+
+		fmt.Printf("\nUnmapped users; using the PagerDuty email (user : email)\n")
+		for _, id := range unmapped {
+			fmt.Printf("%s : %s\n", names[id], participants[id])
+		}
+		fmt.Printf("\n")
+	}
+
+	return mapped, nil
+}
+
+// publish the shifts of each schedule as events in Google Calendar
+func syncSchedules(schedules []*pduty.Schedule, participants map[string]string, start time.Time, end time.Time) {
+	tokenFile := gcalTokenFile
+	if !flagSet("gcal-token") {
+		// publishing needs write access; keep the read-only sign in separate
+		tokenFile = "token-sync.json"
+	}
+
+	calendarAPI := &gcal.CalendarAPI{
+		AuthMode: gcalAuthMode,
+		Subject:  gcalSubject,
+		Reauthenticate: func() bool {
+			return confirm("Your Google sign in has expired or been revoked. Sign in again?")
+		},
+	}
+
+	var err error
+	calendarAPI.Tokens, err = newTokenStore(tokenFile)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		flag.PrintDefaults()
+		return
+	}
+
+	for _, schedule := range schedules {
+		fmt.Printf("\nPublishing schedule: %s (%s)\n", schedule.Name, schedule.ID)
+		result, err := calendarAPI.Sync(gcalCredentials, tokenFile, schedule, participants, syncCalendar, start, end)
+		if err != nil {
+			fmt.Printf("failed to publish schedule %s: %s\n", schedule.ID, err)
+			return
+		}
+
+		fmt.Printf("created %d, updated %d, deleted %d, unchanged %d\n", result.Created, result.Updated, result.Deleted, result.Unchanged)
+		if len(result.Skipped) > 0 {
+			fmt.Printf("WARNING: no email for %s; their shifts were not published\n", strings.Join(result.Skipped, ", "))
+		}
+	}
+}
+
+// returns true when the flag was supplied on the command line
+func flagSet(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func loadHolidays(participants map[string]string, calendars map[string]*gcal.Calendar, start time.Time, end time.Time) (map[string][]*holiday.Holiday, error) {
+	config, err := holiday.LoadConfig(holidaysFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// users with an unavailable calendar are already reported as unknown (and their time zone is not known)
+	users := map[string]string{}
+	timeZones := map[string]string{}
+	for id, email := range participants {
+		if calendar := calendars[id]; calendar != nil && calendar.Err == nil {
+			users[id] = email
+			timeZones[id] = calendar.TimeZone
+		}
+	}
+
+	fmt.Printf("Loading public holidays for scheduled users\n")
+	return (&holiday.API{Config: config, TimeZones: timeZones}).GetHolidays(users, start, end)
+}
+
+// report the shifts on a public holiday; shifts that already have a (hard) conflict are not repeated
+func checkHolidays(schedule *pduty.Schedule, holidays map[string][]*holiday.Holiday, conflicts []*pduty.ScheduleEntry) {
+	holidayConflicts, err := (&conflict.CheckerAPI{}).CheckHolidays(schedule, holidays)
+	if err != nil {
+		panic(err)
+	}
+
+	reported := map[*pduty.ScheduleEntry]bool{}
+	for _, scheduleEntry := range conflicts {
+		reported[scheduleEntry] = true
+	}
+
+	var remaining []*conflict.HolidayConflict
+	for _, holidayConflict := range holidayConflicts {
+		if !reported[holidayConflict.Entry] {
+			remaining = append(remaining, holidayConflict)
+		}
+	}
+
+	if len(remaining) == 0 {
+		return
+	}
+
+	fmt.Printf("Public holiday - soft conflict (slot : user : holiday)\n")
+	for _, holidayConflict := range remaining {
+		entry := holidayConflict.Entry
+		fmt.Printf("%s to %s : %s : %s (%s)\n", entry.Start.Format(timeFormat), entry.End.Format(timeFormat), entry.User.Name,
+			holidayConflict.Holiday.Name, holidayConflict.Holiday.Region)
+	}
+}
+
+func checkReachability(schedule *pduty.Schedule, setups map[string]*pduty.NotificationSetup) {
+	unreachables, err := (&conflict.CheckerAPI{}).CheckReachability(schedule, setups)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(unreachables) == 0 {
+		return
+	}
+
+	fmt.Printf("Unreachable (slot : user : reasons)\n")
+	for _, unreachable := range unreachables {
+		entry := unreachable.Entry
+		fmt.Printf("%s to %s : %s : %s\n", entry.Start.Format(timeFormat), entry.End.Format(timeFormat), entry.User.Name, strings.Join(unreachable.Reasons, ", "))
+	}
+}
+
+func checkForDoubleBookings(schedules []*pduty.Schedule, hoursOfRest int64) {
+	fmt.Printf("\nChecking for double bookings across schedules\n")
+	doubleBookings, err := (&conflict.CheckerAPI{}).CheckDoubleBookings(schedules, time.Duration(hoursOfRest)*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(doubleBookings) == 0 {
+		log.Printf("No double bookings found")
+		return
+	}
+
+	fmt.Printf("Double booking (user : slot - schedule & slot - schedule)\n")
+	for _, booking := range doubleBookings {
+		problem := "not enough rest"
+		if booking.Overlap {
+			problem = "overlap"
+		}
+
+		fmt.Printf("%s : %s to %s - %s & %s to %s - %s (%s)\n", booking.First.User.Name,
+			booking.First.Start.Format(timeFormat), booking.First.End.Format(timeFormat), booking.FirstSchedule.Name,
+			booking.Second.Start.Format(timeFormat), booking.Second.End.Format(timeFormat), booking.SecondSchedule.Name,
+			problem)
+	}
+}
+
+// attribute the incidents of the last few days to the users that were on-call
+func reportLoad(pdOptions []pduty.Option, schedule *pduty.Schedule) map[string]*conflict.UserLoad {
+	if len(schedule.EscalationPolicyIDs) == 0 {
+		fmt.Printf("\nSchedule is not used by any escalation policy; skipping load report\n")
+		return nil
+	}
+
+	end := time.Now()
+	start := end.Add(time.Duration(-loadDays*24) * time.Hour)
+
+	history, err := pduty.NewScheduleAPI(pdOptions...).GetSchedule(schedule.ID, start, end)
+	if err != nil {
+		fmt.Printf("failed to load schedule history: %s\n", describePDError(err))
+		return nil
+	}
+
+	incidents, err := pduty.NewIncidentAPI(pdOptions...).GetIncidents(schedule.EscalationPolicyIDs, start, end)
+	if err != nil {
+		fmt.Printf("failed to load incidents: %s\n", describePDError(err))
+		return nil
+	}
+
+	users, err := pduty.NewUserAPI(pdOptions...).GetUserDetails(history.Entries)
+	if err != nil {
+		fmt.Printf("failed to load users: %s\n", describePDError(err))
+		return nil
+	}
+
+	locations := map[string]*time.Location{}
+	for id, user := range users {
+		location, err := time.LoadLocation(user.TimeZone)
+		if err == nil {
+			locations[id] = location
+		}
+	}
+
+	loads, err := (&conflict.LoadAPI{}).Calculate(history, incidents, locations)
+	if err != nil {
+		panic(err)
 	}
 
-	return conflictsOrdered
+	var ordered []*conflict.UserLoad
+	for _, load := range loads {
+		ordered = append(ordered, load)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Incidents > ordered[j].Incidents
+	})
+
+	fmt.Printf("\nLoad for the last %d days (user : shifts : incidents : after hours : minutes acknowledged)\n", loadDays)
+	for _, load := range ordered {
+		fmt.Printf("%s : %d : %d : %d : %.0f\n", load.User.Name, load.Shifts, load.Incidents, load.AfterHoursPages, load.MinutesAcknowledged)
+	}
+
+	return loads
 }
 
-func findSwaps(periodStart time.Time, schedule *pduty.Schedule, conflicts []*pduty.ScheduleEntry, calendars map[string]*gcal.Calendar) map[*pduty.ScheduleEntry]*pduty.ScheduleEntry {
+func findSwaps(periodStart time.Time, schedule *pduty.Schedule, conflicts []*pduty.ScheduleEntry, calendars map[string]*gcal.Calendar, loads map[string]*conflict.UserLoad) map[*pduty.ScheduleEntry]*pduty.ScheduleEntry {
 	fmt.Printf("\nPotential Swaps (slot - user -> slot - user)\n")
-	swapAPI := &conflict.SwapAPI{}
+	swapAPI := &conflict.SwapAPI{LockOverrides: lockOverrides, Load: loads}
 	swaps := map[*pduty.ScheduleEntry]*pduty.ScheduleEntry{}
 
 	for _, conflict := range conflicts {
@@ -121,8 +729,106 @@ func findSwaps(periodStart time.Time, schedule *pduty.Schedule, conflicts []*pdu
 			continue
 		}
 
-		fmt.Fprintf(os.Stderr, "\n ==> SWAP NOT FOUND FOR %s - %s - %s <==\n\n", conflict.Start.Format(timeFormat), conflict.End.Format(timeFormat), conflict.User.Name)
+		fmt.Fprintf(os.Stderr, "\n ==> SWAP NOT FOUND FOR %s - %s - %s%s <==\n\n", conflict.Start.Format(timeFormat), conflict.End.Format(timeFormat), conflict.User.Name, overrideLabel(conflict))
 	}
 
 	return swaps
 }
+
+// existing overrides that clash are highlighted as they need to be fixed by whoever created them
+func overrideLabel(entry *pduty.ScheduleEntry) string {
+	if !entry.IsOverride {
+		return ""
+	}
+
+	if lockOverrides {
+		return " (existing override, locked)"
+	}
+
+	return " (existing override)"
+}
+
+// turn each conflict/swap pair into 2 overrides and (optionally) create them in PD
+func applySwaps(scheduleAPI *pduty.ScheduleAPI, schedule *pduty.Schedule, conflicts []*pduty.ScheduleEntry, swaps map[*pduty.ScheduleEntry]*pduty.ScheduleEntry) {
+	var overrides []*pduty.Override
+
+	// iterate the conflicts so that the output is ordered
+	for _, conflict := range conflicts {
+		swap, found := swaps[conflict]
+		if !found {
+			continue
+		}
+
+		overrides = append(overrides,
+			&pduty.Override{Start: conflict.Start, End: conflict.End, User: swap.User},
+			&pduty.Override{Start: swap.Start, End: swap.End, User: conflict.User},
+		)
+	}
+
+	if !applyOverrides {
+		fmt.Printf("\nDry run - the following overrides would be created (use -apply to create them)\n")
+		for _, override := range overrides {
+			fmt.Printf("%s to %s : %s\n", override.Start.Format(timeFormat), override.End.Format(timeFormat), override.User.Name)
+		}
+		return
+	}
+
+	if !assumeYes && !confirm(fmt.Sprintf("\nCreate %d overrides on schedule %s?", len(overrides), schedule.Name)) {
+		fmt.Printf("No overrides created\n")
+		return
+	}
+
+	fmt.Printf("\nCreating overrides (slot : user : result)\n")
+	created := 0
+
+	for _, override := range overrides {
+		slot := fmt.Sprintf("%s to %s : %s", override.Start.Format(timeFormat), override.End.Format(timeFormat), override.User.Name)
+
+		_, err := scheduleAPI.CreateOverride(schedule.ID, override)
+		if err != nil {
+			fmt.Printf("%s : FAILED (%s)\n", slot, describePDError(err))
+			continue
+		}
+
+		fmt.Printf("%s : OK\n", slot)
+		created++
+	}
+
+	fmt.Printf("Created %d of %d overrides\n", created, len(overrides))
+}
+
+// shared by all prompts; a reader per prompt would lose the answers it buffered (e.g. when the input is piped)
+var stdin = bufio.NewReader(os.Stdin)
+
+// ask the user a yes/no question on the terminal
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// turn PD errors into something the user can act on
+func describePDError(err error) string {
+	switch {
+	case errors.Is(err, pduty.ErrUnauthorized):
+		return "PagerDuty rejected the API key; check PD_API_KEY (" + err.Error() + ")"
+
+	case errors.Is(err, pduty.ErrNotFound):
+		return "not found in PagerDuty; check the ID (" + err.Error() + ")"
+
+	case errors.Is(err, pduty.ErrRateLimited):
+		return "PagerDuty is rate limiting this API key; try again in a few minutes (" + err.Error() + ")"
+
+	case errors.Is(err, pduty.ErrServerError):
+		return "PagerDuty is having problems; try again later (" + err.Error() + ")"
+
+	default:
+		return err.Error()
+	}
+}